@@ -0,0 +1,115 @@
+// Package admin provides HTTP endpoints for operating a service: log
+// levels, profiling, health, metrics and build info
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/go-xtek/vuvo-go/health"
+	"github.com/go-xtek/vuvo-go/l"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var ll = l.New()
+
+// Config ...
+type Config struct {
+	Name    string
+	Version string
+
+	// Health reports readiness on /readyz, it is always ready when nil
+	Health *health.Server
+}
+
+// BuildInfo is returned by /version
+type BuildInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path,omitempty"`
+	Module    string `json:"module_version,omitempty"`
+}
+
+// NewMux returns a mux serving
+//
+//	/debug/log     log levels, see l.ServeHTTP
+//	/debug/pprof/  profiles, see net/http/pprof
+//	/healthz       liveness
+//	/readyz        readiness with results of health checkers
+//	/metrics       prometheus metrics
+//	/version       build info
+func NewMux(cfg Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/log", l.ServeHTTP)
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readyz(w, cfg.Health)
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+
+	info := buildInfo(cfg)
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	})
+	return mux
+}
+
+func readyz(w http.ResponseWriter, h *health.Server) {
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+	if h == nil {
+		writeJSON(w, http.StatusOK, response{Status: "SERVING"})
+		return
+	}
+
+	resp := response{Status: "SERVING", Checks: make(map[string]string)}
+	for name, err := range h.Results() {
+		resp.Checks[name] = "ok"
+		if err != nil {
+			resp.Checks[name] = err.Error()
+		}
+	}
+	code := http.StatusOK
+	if !h.Serving() {
+		resp.Status = "NOT_SERVING"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+func buildInfo(cfg Config) BuildInfo {
+	info := BuildInfo{
+		Name:      cfg.Name,
+		Version:   cfg.Version,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Main.Path
+		info.Module = bi.Main.Version
+	}
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		ll.Error("Admin: unable to write response", l.Error(err))
+	}
+}
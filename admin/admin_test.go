@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-xtek/vuvo-go/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(mux *http.ServeMux, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestMux(t *testing.T) {
+	var redisErr error
+	h := health.NewServer(health.Config{
+		Checkers: map[string]health.Checker{
+			"redis": func(ctx context.Context) error { return redisErr },
+		},
+	})
+	mux := NewMux(Config{Name: "foo", Version: "1.2.3", Health: h})
+
+	assert.Equal(t, http.StatusOK, get(mux, "/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(mux, "/readyz").Code, "not ready before the first check")
+
+	h.CheckNow(context.Background())
+	w := get(mux, "/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "SERVING", "checks": {"redis": "ok"}}`, w.Body.String())
+
+	redisErr = errors.New("connection refused")
+	h.CheckNow(context.Background())
+	w = get(mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status": "NOT_SERVING", "checks": {"redis": "connection refused"}}`, w.Body.String())
+
+	w = get(mux, "/version")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"version":"1.2.3"`)
+
+	w = get(mux, "/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+
+	assert.Equal(t, http.StatusOK, get(mux, "/debug/log").Code)
+	assert.Equal(t, http.StatusOK, get(mux, "/debug/pprof/").Code)
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/idgen"
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/redis"
+)
+
+const (
+	// DefaultAPIKeyPrefix is used when no API key prefix is provided
+	DefaultAPIKeyPrefix = "AK"
+
+	// APIKeySecretLength ...
+	APIKeySecretLength = 32 // In bytes
+)
+
+// ErrInvalidAPIKey returns an error indicate that
+// the API key is invalid
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+var apiKeyInfix = idgen.CalcInfix("AK")
+
+// APIKey contains information of an issued API key. The secret part of the
+// key is never stored.
+type APIKey struct {
+	ID       string
+	Provider ServiceProviderClaim
+
+	// Methods lists full method names the key is allowed to call,
+	// empty means all methods
+	Methods []string
+
+	CreatedAt time.Time
+	ExpiresAt time.Time // Zero means the key never expires
+}
+
+// Allows reports whether the key is allowed to call given method
+func (k APIKey) Allows(fullMethod string) bool {
+	if len(k.Methods) == 0 {
+		return true
+	}
+	for _, m := range k.Methods {
+		if m == fullMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyValidator interface
+type APIKeyValidator interface {
+	ValidateAPIKey(key string) (APIKey, error)
+}
+
+// APIKeyStore interface contains methods
+// to manage API keys
+type APIKeyStore interface {
+	APIKeyValidator
+
+	// Issue creates new key for given provider. The returned key string is
+	// only available at this time.
+	Issue(provider ServiceProviderClaim, methods []string, ttl int) (string, APIKey, error)
+	Revoke(id string) error
+	List(providerID string) ([]APIKey, error)
+}
+
+type apiKeyRecord struct {
+	APIKey
+	Hash string
+}
+
+type apiKeyStore struct {
+	prefix     string
+	redisStore redis.Store
+}
+
+// NewAPIKeyStore returns new API key store. Issued keys have the form
+// <prefix>_<id>.<secret>, so the prefix must not contain "_".
+func NewAPIKeyStore(prefix string, r redis.Store) APIKeyStore {
+	if prefix == "" {
+		prefix = DefaultAPIKeyPrefix
+	}
+	if strings.Contains(prefix, "_") {
+		panic("auth: API key prefix must not contain `_`")
+	}
+	return &apiKeyStore{
+		prefix:     prefix,
+		redisStore: r,
+	}
+}
+
+func (s *apiKeyStore) toKey(id string) string {
+	return s.prefix + ":apikey:" + id
+}
+
+// parse splits given API key into its id and secret
+func (s *apiKeyStore) parse(key string) (id, secret string, ok bool) {
+	if !strings.HasPrefix(key, s.prefix+"_") {
+		return "", "", false
+	}
+	parts := strings.SplitN(key[len(s.prefix)+1:], ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates API key for given provider. A ttl of 0 creates a key which
+// never expires.
+func (s *apiKeyStore) Issue(provider ServiceProviderClaim, methods []string, ttl int) (string, APIKey, error) {
+	if provider.ID == "" {
+		return "", APIKey{}, errors.New("Provider ID required")
+	}
+
+	now := time.Now()
+	record := apiKeyRecord{
+		APIKey: APIKey{
+			ID:        idgen.Generate(apiKeyInfix).String(),
+			Provider:  provider,
+			Methods:   methods,
+			CreatedAt: now,
+		},
+	}
+	secret := RandomToken(APIKeySecretLength)
+	record.Hash = hashAPIKeySecret(secret)
+
+	var err error
+	if ttl > 0 {
+		record.ExpiresAt = now.Add(time.Duration(ttl) * time.Second)
+		err = s.redisStore.SetWithTTL(s.toKey(record.ID), record, ttl)
+	} else {
+		err = s.redisStore.Set(s.toKey(record.ID), record)
+	}
+	if err != nil {
+		return "", APIKey{}, err
+	}
+
+	key := s.prefix + "_" + record.ID + "." + secret
+	return key, record.APIKey, nil
+}
+
+// Revoke deletes API key with given id
+func (s *apiKeyStore) Revoke(id string) error {
+	err := s.redisStore.Del(s.toKey(id))
+	if err != nil {
+		ll.Error("Error revoking API key", l.String("id", id), l.Error(err))
+	}
+	return err
+}
+
+// List returns all API keys of given provider
+func (s *apiKeyStore) List(providerID string) ([]APIKey, error) {
+	keys, err := s.redisStore.GetStrings(s.toKey("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []APIKey
+	for _, k := range keys {
+		var record apiKeyRecord
+		if err := s.redisStore.Get(k, &record); err != nil {
+			// The key may be expired or revoked in the meantime
+			continue
+		}
+		if record.Provider.ID == providerID {
+			result = append(result, record.APIKey)
+		}
+	}
+	return result, nil
+}
+
+// ValidateAPIKey returns information of given API key
+func (s *apiKeyStore) ValidateAPIKey(key string) (APIKey, error) {
+	id, secret, ok := s.parse(key)
+	if !ok {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	var record apiKeyRecord
+	if err := s.redisStore.Get(s.toKey(id), &record); err != nil {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	hash := hashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(record.Hash)) != 1 {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if !record.ExpiresAt.IsZero() && time.Now().After(record.ExpiresAt) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	return record.APIKey, nil
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(T *testing.T) {
+	s := NewAPIKeyStore("TK", rStore)
+	provider := ServiceProviderClaim{ID: "p1", Codename: "partner", Name: "Partner"}
+
+	key, info, err := s.Issue(provider, []string{"/foo.Foo/Bar"}, 0)
+	require.NoError(T, err)
+	defer s.Revoke(info.ID)
+
+	T.Run("Validate", func(t *testing.T) {
+		got, err := s.ValidateAPIKey(key)
+		require.NoError(t, err)
+		assert.Equal(t, provider, got.Provider)
+		assert.True(t, got.Allows("/foo.Foo/Bar"))
+		assert.False(t, got.Allows("/foo.Foo/Baz"))
+	})
+
+	T.Run("Wrong secret", func(t *testing.T) {
+		_, err := s.ValidateAPIKey(key + "x")
+		assert.Equal(t, ErrInvalidAPIKey, err)
+
+		_, err = s.ValidateAPIKey("XX_" + info.ID + ".secret")
+		assert.Equal(t, ErrInvalidAPIKey, err)
+	})
+
+	T.Run("List", func(t *testing.T) {
+		keys, err := s.List(provider.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, info.ID, keys[0].ID)
+	})
+
+	T.Run("Revoke", func(t *testing.T) {
+		require.NoError(t, s.Revoke(info.ID))
+
+		_, err := s.ValidateAPIKey(key)
+		assert.Equal(t, ErrInvalidAPIKey, err)
+	})
+}
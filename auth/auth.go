@@ -9,6 +9,10 @@ import (
 // Claim contains information for current user
 type Claim struct {
 	Token Token
+
+	// Source is the name of the validator authenticating the token,
+	// only set when the validator is a SourceValidator
+	Source string
 }
 
 type keyClaim struct{}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNegativeCacheSize is the maximum number of invalid tokens
+	// remembered by a chain validator
+	DefaultNegativeCacheSize = 10000
+)
+
+// SourceValidator is implemented by validators which report the name of
+// the validator authenticating the token
+type SourceValidator interface {
+	Validator
+	ValidateWithSource(tokenStr string) (token Token, source string, err error)
+}
+
+// ValidatorRule registers a validator in a chain
+type ValidatorRule struct {
+	Name      string
+	Validator Validator
+
+	// Match reports whether the token should be handled by Validator,
+	// nil matches all tokens
+	Match func(tokenStr string) bool
+}
+
+// HasPrefix matches tokens starting with given prefix
+func HasPrefix(prefix string) func(tokenStr string) bool {
+	return func(tokenStr string) bool {
+		return strings.HasPrefix(tokenStr, prefix)
+	}
+}
+
+// IsJWT matches tokens in JWT compact serialization format
+func IsJWT(tokenStr string) bool {
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, p := range parts[:2] {
+		if p == "" {
+			return false
+		}
+	}
+	return strings.HasPrefix(parts[0], "eyJ")
+}
+
+// ChainOption allows optional config for chain validator
+type ChainOption func(c *chainValidator)
+
+// WithNegativeCache remembers invalid tokens for given duration, so repeated
+// invalid tokens are rejected without hitting the validators. Only tokens
+// rejected without transient errors are remembered, see IsTemporary.
+func WithNegativeCache(ttl time.Duration, size int) ChainOption {
+	if size <= 0 {
+		size = DefaultNegativeCacheSize
+	}
+	return func(c *chainValidator) {
+		c.negativeTTL = ttl
+		c.negativeSize = size
+	}
+}
+
+type chainValidator struct {
+	rules []ValidatorRule
+
+	negativeTTL  time.Duration
+	negativeSize int
+
+	mu       sync.Mutex
+	negative map[string]time.Time
+}
+
+// NewChainValidator returns a validator which tries given validators in
+// order, skipping those whose Match rejects the token
+func NewChainValidator(rules []ValidatorRule, opts ...ChainOption) SourceValidator {
+	c := &chainValidator{
+		rules:    rules,
+		negative: make(map[string]time.Time),
+	}
+	for _, fn := range opts {
+		fn(c)
+	}
+	return c
+}
+
+func (c *chainValidator) Validate(tokenStr string) (Token, error) {
+	t, _, err := c.ValidateWithSource(tokenStr)
+	return t, err
+}
+
+func (c *chainValidator) ValidateWithSource(tokenStr string) (Token, string, error) {
+	cacheKey := ""
+	if c.negativeTTL > 0 {
+		sum := sha256.Sum256([]byte(tokenStr))
+		cacheKey = hex.EncodeToString(sum[:])
+		if c.isNegative(cacheKey) {
+			return Token{TokenStr: tokenStr}, "", ErrInvalid
+		}
+	}
+
+	var lastErr error
+	for _, r := range c.rules {
+		if r.Match != nil && !r.Match(tokenStr) {
+			continue
+		}
+		t, err := r.Validator.Validate(tokenStr)
+		if err == nil {
+			return t, r.Name, nil
+		}
+		if IsTemporary(err) {
+			lastErr = err
+		}
+	}
+
+	// Transient errors, e.g. the repository is unreachable, are returned as
+	// the token may be valid on next request. Other errors mean the token
+	// is invalid.
+	if lastErr != nil {
+		return Token{TokenStr: tokenStr}, "", lastErr
+	}
+	if cacheKey != "" {
+		c.addNegative(cacheKey)
+	}
+	return Token{TokenStr: tokenStr}, "", ErrInvalid
+}
+
+func (c *chainValidator) isNegative(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiredAt, ok := c.negative[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiredAt) {
+		delete(c.negative, key)
+		return false
+	}
+	return true
+}
+
+func (c *chainValidator) addNegative(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.negative) >= c.negativeSize {
+		for k, expiredAt := range c.negative {
+			if now.After(expiredAt) {
+				delete(c.negative, k)
+			}
+		}
+	}
+	if len(c.negative) >= c.negativeSize {
+		c.negative = make(map[string]time.Time)
+	}
+	c.negative[key] = now.Add(c.negativeTTL)
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countValidator struct {
+	valid string
+	calls int
+}
+
+func (v *countValidator) Validate(tokenStr string) (Token, error) {
+	v.calls++
+	if tokenStr != v.valid {
+		return Token{}, ErrInvalid
+	}
+	return Token{TokenStr: tokenStr, UserID: v.valid}, nil
+}
+
+func TestChainValidator(T *testing.T) {
+	jwt := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"
+	legacy := &countValidator{valid: "opaque"}
+	modern := &countValidator{valid: jwt}
+
+	c := NewChainValidator([]ValidatorRule{
+		{Name: "jwt", Validator: modern, Match: IsJWT},
+		{Name: "legacy", Validator: legacy},
+	}, WithNegativeCache(time.Minute, 0))
+
+	T.Run("Dispatch by format", func(t *testing.T) {
+		tok, source, err := c.ValidateWithSource(jwt)
+		require.NoError(t, err)
+		assert.Equal(t, "jwt", source)
+		assert.Equal(t, jwt, tok.UserID)
+		assert.Equal(t, 0, legacy.calls)
+	})
+
+	T.Run("Fallback in order", func(t *testing.T) {
+		_, source, err := c.ValidateWithSource("opaque")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy", source)
+		assert.Equal(t, 1, modern.calls)
+	})
+
+	T.Run("Negative cache", func(t *testing.T) {
+		_, err := c.Validate("invalid")
+		assert.Equal(t, ErrInvalid, err)
+		calls := legacy.calls
+
+		_, err = c.Validate("invalid")
+		assert.Equal(t, ErrInvalid, err)
+		assert.Equal(t, calls, legacy.calls)
+	})
+}
+
+type failingValidator struct {
+	calls int
+}
+
+func (v *failingValidator) Validate(tokenStr string) (Token, error) {
+	v.calls++
+	return Token{}, Temporary(errors.New("connection refused"))
+}
+
+func TestChainValidatorTransientError(t *testing.T) {
+	failing := &failingValidator{}
+	c := NewChainValidator([]ValidatorRule{
+		{Name: "redis", Validator: failing},
+		{Name: "legacy", Validator: &countValidator{valid: "opaque"}},
+	}, WithNegativeCache(time.Minute, 0))
+
+	_, err := c.Validate("token")
+	assert.EqualError(t, err, "connection refused")
+	_, err = c.Validate("token")
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 2, failing.calls)
+
+	_, err = c.Validate("opaque")
+	assert.NoError(t, err)
+}
+
+type parseErrorValidator struct {
+	calls int
+}
+
+func (v *parseErrorValidator) Validate(tokenStr string) (Token, error) {
+	v.calls++
+	return Token{}, errors.New("token contains an invalid number of segments")
+}
+
+func TestChainValidatorUnmarkedError(t *testing.T) {
+	malformed := &parseErrorValidator{}
+	c := NewChainValidator([]ValidatorRule{
+		{Name: "jwt", Validator: malformed},
+	}, WithNegativeCache(time.Minute, 0))
+
+	// Errors not marked as temporary mean the token is invalid
+	_, err := c.Validate("garbage")
+	assert.Equal(t, ErrInvalid, err)
+	_, err = c.Validate("garbage")
+	assert.Equal(t, ErrInvalid, err)
+	assert.Equal(t, 1, malformed.calls)
+}
+
+func TestTemporary(t *testing.T) {
+	err := errors.New("connection refused")
+	assert.False(t, IsTemporary(err))
+	assert.False(t, IsTemporary(nil))
+	assert.False(t, IsTemporary(ErrInvalid))
+	assert.True(t, IsTemporary(Temporary(err)))
+	assert.EqualError(t, Temporary(err), "connection refused")
+	assert.Nil(t, Temporary(nil))
+}
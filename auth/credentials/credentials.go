@@ -0,0 +1,275 @@
+// Package credentials hashes and verifies passwords before tokens are issued
+// with auth.Generator
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-xtek/vuvo-go/l"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	// ErrMismatch returns an error indicate that
+	// the password does not match the hash
+	ErrMismatch = errors.New("Password does not match")
+
+	// ErrUnknownHash returns an error indicate that
+	// the hash is not produced by a supported algorithm
+	ErrUnknownHash = errors.New("Unknown password hash")
+
+	// ErrMalformedHash returns an error indicate that
+	// the parameters of the hash can not be parsed
+	ErrMalformedHash = errors.New("Malformed password hash")
+)
+
+var ll = l.New()
+
+// Argon2Params configures argon2id hashes, they are encoded in the hash so
+// they can be changed without invalidating existing hashes
+type Argon2Params struct {
+	Memory      uint32 // In KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the second recommended option of RFC 9106
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes new passwords with its configured algorithm and verifies
+// passwords against hashes of all supported algorithms
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+
+	// dummy is verified for unknown users, so they take as long as
+	// existing ones
+	dummy string
+}
+
+// Option allows optional config for Hasher
+type Option func(h *Hasher)
+
+// WithArgon2 hashes new passwords with argon2id and given parameters
+func WithArgon2(p Argon2Params) Option {
+	return func(h *Hasher) {
+		h.algorithm = Argon2id
+		h.argon2 = p
+	}
+}
+
+// WithBcrypt hashes new passwords with bcrypt and given cost
+func WithBcrypt(cost int) Option {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		panic("credentials: invalid bcrypt cost")
+	}
+	return func(h *Hasher) {
+		h.algorithm = Bcrypt
+		h.bcryptCost = cost
+	}
+}
+
+// NewHasher returns new hasher, which uses argon2id with
+// DefaultArgon2Params unless configured otherwise. It returns an error if
+// the argon2id parameters are invalid or a password can not be hashed.
+func NewHasher(opts ...Option) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  Argon2id,
+		argon2:     DefaultArgon2Params,
+		bcryptCost: bcrypt.DefaultCost,
+	}
+	for _, fn := range opts {
+		fn(h)
+	}
+
+	if h.algorithm == Argon2id {
+		p := h.argon2
+		if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.SaltLength == 0 || p.KeyLength == 0 {
+			return nil, errors.New("Credentials: invalid argon2id parameters")
+		}
+	}
+	dummy, err := h.Hash(RandomPassword())
+	if err != nil {
+		return nil, fmt.Errorf("Credentials: unable to hash: %v", err)
+	}
+	h.dummy = dummy
+	return h, nil
+}
+
+// Hash returns the encoded hash of given password
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == Bcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(b), err
+	}
+
+	p := h.argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return encodeArgon2(p, salt, key), nil
+}
+
+// Verify checks given password against the encoded hash in constant time.
+// When the hash uses another algorithm or outdated parameters, the password
+// is hashed again and passed to update, so the stored hash is upgraded on
+// login. Errors of update are logged without failing the verification.
+func (h *Hasher) Verify(password, encoded string, update func(encoded string) error) error {
+	if err := verify(password, encoded); err != nil {
+		return err
+	}
+	if update == nil || !h.NeedsRehash(encoded) {
+		return nil
+	}
+
+	newHash, err := h.Hash(password)
+	if err == nil {
+		err = update(newHash)
+	}
+	if err != nil {
+		ll.Error("Credentials: unable to rehash password", l.Error(err))
+	}
+	return nil
+}
+
+// VerifyMissing spends as long as Verify and returns ErrMismatch. It should
+// be called when the user does not exist, so timing does not reveal
+// which users exist.
+func (h *Hasher) VerifyMissing(password string) error {
+	_ = verify(password, h.dummy)
+	return ErrMismatch
+}
+
+// NeedsRehash reports whether given hash uses another algorithm or other
+// parameters than the hasher
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch algorithmOf(encoded) {
+	case Argon2id:
+		if h.algorithm != Argon2id {
+			return true
+		}
+		p, _, _, err := decodeArgon2(encoded)
+		return err != nil || p != h.argon2
+
+	case Bcrypt:
+		if h.algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.bcryptCost
+
+	default:
+		return true
+	}
+}
+
+func verify(password, encoded string) error {
+	switch algorithmOf(encoded) {
+	case Argon2id:
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+
+	case Bcrypt:
+		// bcrypt compares in constant time
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		switch err {
+		case nil:
+			return nil
+		case bcrypt.ErrMismatchedHashAndPassword:
+			return ErrMismatch
+		default:
+			return ErrMalformedHash
+		}
+
+	default:
+		return ErrUnknownHash
+	}
+}
+
+func algorithmOf(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return Argon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return Bcrypt
+	default:
+		return ""
+	}
+}
+
+// encodeArgon2 returns the hash in PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func encodeArgon2(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(encoded string) (p Argon2Params, salt, key []byte, err error) {
+	s := strings.Split(encoded, "$")
+	if len(s) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(s[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+	_, err = fmt.Sscanf(s[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(s[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(s[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
+
+// RandomPassword returns a random password, e.g. for accounts which only
+// sign in with external providers
+func RandomPassword() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,136 @@
+package credentials
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2(t *testing.T) {
+	h, err := NewHasher(WithArgon2(testArgon2Params))
+	require.NoError(t, err)
+
+	encoded, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"), encoded)
+	assert.False(t, h.NeedsRehash(encoded))
+
+	other, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, other, "salt must be random")
+
+	assert.NoError(t, h.Verify("correct horse", encoded, nil))
+	assert.Equal(t, ErrMismatch, h.Verify("battery staple", encoded, nil))
+	assert.Equal(t, ErrMismatch, h.VerifyMissing("correct horse"))
+}
+
+func TestBcrypt(t *testing.T) {
+	h, err := NewHasher(WithBcrypt(bcrypt.MinCost))
+	require.NoError(t, err)
+
+	encoded, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.False(t, h.NeedsRehash(encoded))
+	assert.NoError(t, h.Verify("correct horse", encoded, nil))
+	assert.Equal(t, ErrMismatch, h.Verify("battery staple", encoded, nil))
+}
+
+func TestMalformedHash(t *testing.T) {
+	h, err := NewHasher(WithArgon2(testArgon2Params))
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrUnknownHash, h.Verify("pw", "plain", nil))
+	assert.Equal(t, ErrMalformedHash, h.Verify("pw", "$argon2id$v=19$m=1024$abc$def", nil))
+	assert.Equal(t, ErrMalformedHash, h.Verify("pw", "$argon2id$v=18$m=1024,t=1,p=1$YWJj$ZGVm", nil))
+	assert.Equal(t, ErrMalformedHash, h.Verify("pw", "$2a$04$short", nil))
+	assert.True(t, h.NeedsRehash("plain"))
+}
+
+func TestRehashOnLogin(t *testing.T) {
+	old, err := NewHasher(WithBcrypt(bcrypt.MinCost))
+	require.NoError(t, err)
+	encoded, err := old.Hash("correct horse")
+	require.NoError(t, err)
+
+	h, err := NewHasher(WithArgon2(testArgon2Params))
+	require.NoError(t, err)
+	assert.True(t, h.NeedsRehash(encoded))
+
+	var updated string
+	err = h.Verify("correct horse", encoded, func(s string) error {
+		updated = s
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(updated, "$argon2id$"))
+	assert.NoError(t, h.Verify("correct horse", updated, nil))
+
+	// Changed parameters
+	p := testArgon2Params
+	p.Iterations = 2
+	h2, err := NewHasher(WithArgon2(p))
+	require.NoError(t, err)
+	assert.True(t, h2.NeedsRehash(updated))
+
+	// Failed update does not fail login
+	err = h2.Verify("correct horse", updated, func(string) error {
+		return errors.New("db down")
+	})
+	assert.NoError(t, err)
+
+	// Wrong password never updates
+	err = h2.Verify("battery staple", updated, func(string) error {
+		t.Fatal("update called")
+		return nil
+	})
+	assert.Equal(t, ErrMismatch, err)
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{
+		MinLength:    10,
+		RequireUpper: true,
+		RequireDigit: true,
+		Denylist:     []string{"Password123"},
+	}
+
+	assert.NoError(t, p.Check("Tr0ub4dor&3x"))
+
+	err := p.Check("short")
+	require.IsType(t, &PolicyError{}, err)
+	assert.Equal(t, []string{ReasonTooShort, ReasonNoUpper, ReasonNoDigit}, err.(*PolicyError).Reasons)
+
+	err = p.Check("password123")
+	require.IsType(t, &PolicyError{}, err)
+	assert.Equal(t, []string{ReasonNoUpper, ReasonCommon}, err.(*PolicyError).Reasons)
+
+	err = p.Check("Alice2020xyz", "alice@example.com", "alice")
+	require.IsType(t, &PolicyError{}, err)
+	assert.Equal(t, []string{ReasonUserDerived}, err.(*PolicyError).Reasons)
+
+	err = p.Check(strings.Repeat("A1", 40))
+	require.IsType(t, &PolicyError{}, err)
+	assert.Equal(t, []string{ReasonTooLong}, err.(*PolicyError).Reasons)
+}
+
+func TestNewHasherInvalidConfig(t *testing.T) {
+	p := testArgon2Params
+	p.Parallelism = 0
+	_, err := NewHasher(WithArgon2(p))
+	assert.EqualError(t, err, "Credentials: invalid argon2id parameters")
+
+	_, err = NewHasher(WithArgon2(Argon2Params{}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package credentials
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reasons of PolicyError
+const (
+	ReasonTooShort    = "too_short"
+	ReasonTooLong     = "too_long"
+	ReasonNoUpper     = "no_upper"
+	ReasonNoLower     = "no_lower"
+	ReasonNoDigit     = "no_digit"
+	ReasonNoSymbol    = "no_symbol"
+	ReasonCommon      = "common"
+	ReasonUserDerived = "user_derived"
+)
+
+// DefaultMaxLength is the limit of bcrypt, which ignores following bytes
+const DefaultMaxLength = 72
+
+// Policy describes requirements of new passwords
+type Policy struct {
+	MinLength int // In characters
+	MaxLength int // In bytes, DefaultMaxLength when 0
+
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// Denylist contains common passwords, compared case-insensitively
+	Denylist []string
+}
+
+// PolicyError lists all requirements a password fails
+type PolicyError struct {
+	Reasons []string
+}
+
+func (e *PolicyError) Error() string {
+	return "Password does not satisfy policy: " + strings.Join(e.Reasons, ", ")
+}
+
+// Check returns a *PolicyError if given password does not satisfy the
+// policy. userInputs, e.g. username and email, must not be contained in
+// the password.
+func (p Policy) Check(password string, userInputs ...string) error {
+	var reasons []string
+	maxLength := p.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLength
+	}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		reasons = append(reasons, ReasonTooShort)
+	}
+	if len(password) > maxLength {
+		reasons = append(reasons, ReasonTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		reasons = append(reasons, ReasonNoUpper)
+	}
+	if p.RequireLower && !lower {
+		reasons = append(reasons, ReasonNoLower)
+	}
+	if p.RequireDigit && !digit {
+		reasons = append(reasons, ReasonNoDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		reasons = append(reasons, ReasonNoSymbol)
+	}
+
+	lowered := strings.ToLower(password)
+	for _, common := range p.Denylist {
+		if lowered == strings.ToLower(common) {
+			reasons = append(reasons, ReasonCommon)
+			break
+		}
+	}
+	for _, input := range userInputs {
+		// Short inputs would reject too many passwords
+		if len(input) >= 3 && strings.Contains(lowered, strings.ToLower(input)) {
+			reasons = append(reasons, ReasonUserDerived)
+			break
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &PolicyError{Reasons: reasons}
+	}
+	return nil
+}
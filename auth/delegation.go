@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// DelegationMaxTTL is the maximum TTL of delegated tokens in seconds
+const DelegationMaxTTL = 15 * 60
+
+// WithDelegation enables GenerateDelegated. Token.ActorID and Token.Scopes
+// are always populated on Validate, so delegated tokens keep their
+// restrictions on generators without this option.
+func WithDelegation() GeneratorOption {
+	return func(g *generator) {
+		g.delegation = true
+	}
+}
+
+// IsDelegated reports whether the token is used by an actor on behalf of
+// another user
+func (t Token) IsDelegated() bool {
+	return t.ActorID != ""
+}
+
+// HasScope reports whether the token is allowed to perform actions of given
+// scope. Tokens which are not delegated have all scopes. Methods are
+// restricted by scope with the RequireScope interceptors of package grpc.
+func (t Token) HasScope(scope string) bool {
+	if !t.IsDelegated() {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Delegator is implemented by generators of delegated tokens, see
+// WithDelegation
+type Delegator interface {
+	GenerateDelegated(actorID, userID string, scopes []string, ttl int) (Token, error)
+}
+
+// GenerateDelegated creates a token for actorID to act as userID, e.g.
+// support staff impersonating a customer. The token is restricted to given
+// scopes and its TTL is bounded by DelegationMaxTTL.
+func (g *generator) GenerateDelegated(actorID, userID string, scopes []string, ttl int) (Token, error) {
+	if !g.delegation {
+		return Token{}, errors.New("Delegation is not enabled")
+	}
+	if actorID == "" || actorID == userID {
+		return Token{}, errors.New("Invalid actor")
+	}
+	if len(scopes) == 0 {
+		return Token{}, errors.New("Delegated token requires scopes")
+	}
+	if ttl <= 0 || ttl > DelegationMaxTTL {
+		ttl = DelegationMaxTTL
+	}
+
+	t := Token{
+		SubjectID: g.name,
+		UserID:    userID,
+		ActorID:   actorID,
+		Scopes:    scopes,
+	}
+	t, err := g.generate(t, ttl)
+	if err != nil {
+		return Token{}, err
+	}
+
+	ll.Info("Generated delegated token", l.String("actor", actorID), l.String("user", userID), l.Int("ttl", ttl))
+	return t, nil
+}
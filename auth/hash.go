@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// WithHashedKeys stores tokens under HMAC-SHA256 digest of the token string,
+// so the repository never holds raw bearer tokens. The secret must be the same for
+// all instances sharing the generator name.
+func WithHashedKeys(secret []byte) GeneratorOption {
+	if len(secret) == 0 {
+		panic("auth: hashed keys require a secret")
+	}
+	return func(g *generator) {
+		g.hashSecret = secret
+	}
+}
+
+// WithLegacyKeys accepts tokens stored under raw keys, which were generated
+// before WithHashedKeys is enabled. Legacy tokens are moved to hashed keys
+// on their first use.
+func WithLegacyKeys() GeneratorOption {
+	return func(g *generator) {
+		g.legacyKeys = true
+	}
+}
+
+// Fingerprint returns a short digest of given token, which is safe to be
+// written to logs
+func Fingerprint(tokenStr string) string {
+	sum := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(sum[:4])
+}
+
+func (g *generator) hashToken(tokenStr string) string {
+	mac := hmac.New(sha256.New, g.hashSecret)
+	mac.Write([]byte(tokenStr))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// toLegacyKey returns repository key of given token before hashed keys are enabled
+func (g *generator) toLegacyKey(t Token) string {
+	return t.SubjectID + ":" + t.TokenStr
+}
+
+// validateLegacy looks up given token under its raw key and moves it to the
+// hashed key, keeping its expiration and attached data
+func (g *generator) validateLegacy(t Token) (TokenRecord, error) {
+	legacyKey := g.toLegacyKey(t)
+	rec, err := g.repo.Get(legacyKey)
+	if err != nil {
+		return rec, err
+	}
+
+	key := g.toKey(t)
+	switch err := g.repo.Rename(legacyKey, key); err {
+	case nil:
+		rec.Key = key
+	case ErrInvalid:
+		// Migrated by a concurrent request
+		return g.repo.Get(key)
+	case ErrTokenExists:
+		if err := g.repo.Delete(legacyKey); err != nil {
+			ll.Error("Error deleting legacy token", l.String("token", Fingerprint(t.TokenStr)), l.Error(err))
+		}
+		return g.repo.Get(key)
+	default:
+		// The legacy key is still usable, try again on next request
+		ll.Error("Error migrating legacy token", l.String("token", Fingerprint(t.TokenStr)), l.Error(err))
+	}
+	return rec, nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// TokenInfo describes a stored token without its raw value
+type TokenInfo struct {
+	// ID identifies the token in admin tools, it can not be used
+	// to authenticate
+	ID string
+
+	SubjectID string
+	UserID    string
+	Value     string
+	TTL       int // Remaining TTL in seconds, -1 means no expiration
+
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// Inspector interface contains methods
+// to inspect and manage stored tokens
+type Inspector interface {
+	Introspect(tokenStr string) (TokenInfo, error)
+	ListTokens(userID string) ([]TokenInfo, error)
+	RevokeByID(id string) error
+	RevokeAllForUser(userID string) (int, error)
+}
+
+// tokenID returns ID of the token stored under given key part
+func tokenID(part string) string {
+	sum := sha256.Sum256([]byte(part))
+	return hex.EncodeToString(sum[:16])
+}
+
+// keyPart returns the token part of given repository key, or false if the
+// key does not store a token of this generator
+func (g *generator) keyPart(key string) (string, bool) {
+	prefix := g.name + ":"
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	part := key[len(prefix):]
+	if part == "" || strings.Contains(part, ":") {
+		return "", false
+	}
+	return part, true
+}
+
+func (g *generator) info(rec TokenRecord) (TokenInfo, bool) {
+	part, ok := g.keyPart(rec.Key)
+	if !ok {
+		return TokenInfo{}, false
+	}
+	return TokenInfo{
+		ID:         tokenID(part),
+		SubjectID:  g.name,
+		UserID:     rec.UserID,
+		Value:      rec.Value,
+		TTL:        rec.TTL,
+		CreatedAt:  rec.CreatedAt,
+		LastSeenAt: rec.LastSeenAt,
+	}, true
+}
+
+// records returns all tokens of this generator
+func (g *generator) records() ([]TokenRecord, error) {
+	recs, err := g.repo.List(g.name + ":")
+	if err != nil {
+		return nil, err
+	}
+
+	result := recs[:0]
+	for _, rec := range recs {
+		if _, ok := g.keyPart(rec.Key); ok {
+			result = append(result, rec)
+		}
+	}
+	return result, nil
+}
+
+// Introspect returns information of given token
+func (g *generator) Introspect(tokenStr string) (TokenInfo, error) {
+	t := Token{
+		TokenStr:  tokenStr,
+		SubjectID: g.name,
+	}
+	rec, err := g.repo.Get(g.toKey(t))
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	info, ok := g.info(rec)
+	if !ok {
+		return TokenInfo{}, ErrInvalid
+	}
+	return info, nil
+}
+
+// ListTokens returns tokens of given user, or all tokens if userID is empty
+func (g *generator) ListTokens(userID string) ([]TokenInfo, error) {
+	recs, err := g.records()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TokenInfo
+	for _, rec := range recs {
+		info, ok := g.info(rec)
+		if !ok || (userID != "" && info.UserID != userID) {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// RevokeByID deletes token with given ID
+func (g *generator) RevokeByID(id string) error {
+	recs, err := g.records()
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range recs {
+		part, _ := g.keyPart(rec.Key)
+		if tokenID(part) == id {
+			return g.repo.Delete(rec.Key)
+		}
+	}
+	return ErrInvalid
+}
+
+// RevokeAllForUser deletes all tokens of given user
+func (g *generator) RevokeAllForUser(userID string) (int, error) {
+	if userID == "" {
+		return 0, ErrInvalid
+	}
+
+	recs, err := g.records()
+	if err != nil {
+		return 0, err
+	}
+
+	var toDelete []string
+	for _, rec := range recs {
+		if rec.UserID == userID {
+			toDelete = append(toDelete, rec.Key)
+		}
+	}
+	if len(toDelete) == 0 {
+		return 0, nil
+	}
+	if err := g.repo.Delete(toDelete...); err != nil {
+		return 0, err
+	}
+	return len(toDelete), nil
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/redis"
+
+	redigo "github.com/garyburd/redigo/redis"
+)
+
+// Charsets for one-time codes
+const (
+	CharsetNumeric      = "0123456789"
+	CharsetAlphanumeric = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // Without ambiguous characters
+)
+
+// Default values of OneTimePurpose
+const (
+	DefaultOneTimeLength      = 6
+	DefaultOneTimeTTL         = 5 * 60
+	DefaultOneTimeMaxAttempts = 5
+)
+
+var (
+	// ErrUnknownPurpose returns an error indicate that
+	// the purpose is not registered
+	ErrUnknownPurpose = errors.New("Unknown purpose")
+
+	// ErrTooManyAttempts returns an error indicate that
+	// the subject is locked out after too many failed attempts
+	ErrTooManyAttempts = errors.New("Too many attempts")
+)
+
+// OneTimePurpose configures one-time codes of a flow,
+// e.g. email verification or password reset
+type OneTimePurpose struct {
+	Name    string
+	Length  int
+	Charset string
+	TTL     int // In seconds
+
+	// MaxAttempts is the number of failed attempts before the code is
+	// discarded and the subject is locked out for LockoutTTL seconds
+	MaxAttempts int
+	LockoutTTL  int
+}
+
+// OneTimeStore issues single-use codes scoped by purpose and subject
+type OneTimeStore interface {
+	// Issue creates new code for given subject, replacing the previous one.
+	// The value is returned when the code is consumed.
+	Issue(purpose, subject, value string) (string, error)
+
+	// Consume validates and deletes the code atomically
+	Consume(purpose, subject, code string) (string, error)
+}
+
+// oneTimeConsumeScript returns the value of the code stored at KEYS[1] if
+// its hash is ARGV[1], and deletes the code and its attempts at KEYS[2]
+const oneTimeConsumeScript = `
+local stored = redis.call("GET", KEYS[1])
+if not stored then
+	return false
+end
+local record = cjson.decode(stored)
+if record.Hash ~= ARGV[1] then
+	return false
+end
+redis.call("DEL", KEYS[1], KEYS[2])
+return record.Value
+`
+
+type oneTimeRecord struct {
+	Hash  string
+	Value string
+}
+
+type oneTimeStore struct {
+	purposes   map[string]OneTimePurpose
+	redisStore redis.AtomicStore
+}
+
+// NewOneTimeStore returns new one-time code store for given purposes
+func NewOneTimeStore(r redis.Store, purposes ...OneTimePurpose) OneTimeStore {
+	s := &oneTimeStore{
+		purposes:   make(map[string]OneTimePurpose),
+		redisStore: atomicStore(r),
+	}
+	for _, p := range purposes {
+		if p.Name == "" {
+			panic("auth: one-time purpose requires a name")
+		}
+		if p.Length == 0 {
+			p.Length = DefaultOneTimeLength
+		}
+		if p.Charset == "" {
+			p.Charset = CharsetNumeric
+		}
+		if p.TTL == 0 {
+			p.TTL = DefaultOneTimeTTL
+		}
+		if p.MaxAttempts == 0 {
+			p.MaxAttempts = DefaultOneTimeMaxAttempts
+		}
+		if p.LockoutTTL == 0 {
+			p.LockoutTTL = p.TTL
+		}
+		s.purposes[p.Name] = p
+	}
+	return s
+}
+
+// toKey and toAttemptsKey end with the subject, so no subject can forge the
+// key of another subject or of its attempts
+func (s *oneTimeStore) toKey(purpose, subject string) string {
+	return "otp:" + purpose + ":code:" + subject
+}
+
+func (s *oneTimeStore) toAttemptsKey(purpose, subject string) string {
+	return "otp:" + purpose + ":attempts:" + subject
+}
+
+func hashOneTimeCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates new code for given subject
+func (s *oneTimeStore) Issue(purpose, subject, value string) (string, error) {
+	p, ok := s.purposes[purpose]
+	if !ok {
+		return "", ErrUnknownPurpose
+	}
+
+	attempts, err := s.redisStore.GetUint64(s.toAttemptsKey(purpose, subject))
+	if err != nil {
+		return "", err
+	}
+	if attempts >= uint64(p.MaxAttempts) {
+		return "", ErrTooManyAttempts
+	}
+
+	code := RandomCode(p.Length, p.Charset)
+	record := oneTimeRecord{
+		Hash:  hashOneTimeCode(code),
+		Value: value,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	if err := s.redisStore.SetStringWithTTL(s.toKey(purpose, subject), string(data), p.TTL); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Consume returns value attached to given code and deletes the code
+func (s *oneTimeStore) Consume(purpose, subject, code string) (string, error) {
+	p, ok := s.purposes[purpose]
+	if !ok {
+		return "", ErrUnknownPurpose
+	}
+
+	key := s.toKey(purpose, subject)
+	attemptsKey := s.toAttemptsKey(purpose, subject)
+	attempts, err := s.redisStore.IncrWithTTL(attemptsKey, p.LockoutTTL)
+	if err != nil {
+		return "", err
+	}
+	if attempts > p.MaxAttempts {
+		if err := s.redisStore.Del(key); err != nil {
+			ll.Error("Error discarding one-time code", l.String("purpose", purpose), l.Error(err))
+		}
+		return "", ErrTooManyAttempts
+	}
+
+	// Only one of concurrent consumers can take the code, and a code issued
+	// meanwhile is never deleted by a consumer of the previous one
+	value, err := redigo.String(s.redisStore.Eval(oneTimeConsumeScript, []string{key, attemptsKey}, hashOneTimeCode(code)))
+	if err == redigo.ErrNil {
+		return "", ErrInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// RandomCode generates a code with given length from characters of charset
+func RandomCode(length int, charset string) string {
+	max := big.NewInt(int64(len(charset)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		code[i] = charset[n.Int64()]
+	}
+	return string(code)
+}
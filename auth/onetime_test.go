@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneTimeStore(T *testing.T) {
+	s := NewOneTimeStore(rStore,
+		OneTimePurpose{Name: "verify-email", MaxAttempts: 2},
+		OneTimePurpose{Name: "reset-password", Length: 20, Charset: CharsetAlphanumeric},
+	)
+
+	T.Run("Consume once", func(t *testing.T) {
+		subject := uuid.NewV4().String()
+		code, err := s.Issue("verify-email", subject, "foo@example.com")
+		require.NoError(t, err)
+		assert.Len(t, code, DefaultOneTimeLength)
+
+		value, err := s.Consume("verify-email", subject, code)
+		require.NoError(t, err)
+		assert.Equal(t, "foo@example.com", value)
+
+		_, err = s.Consume("verify-email", subject, code)
+		assert.Equal(t, ErrInvalid, err)
+	})
+
+	T.Run("Scoped by purpose", func(t *testing.T) {
+		subject := uuid.NewV4().String()
+		code, err := s.Issue("reset-password", subject, "")
+		require.NoError(t, err)
+		assert.Len(t, code, 20)
+
+		_, err = s.Consume("verify-email", subject, code)
+		assert.Equal(t, ErrInvalid, err)
+
+		_, err = s.Consume("unknown", subject, code)
+		assert.Equal(t, ErrUnknownPurpose, err)
+	})
+
+	T.Run("Lockout", func(t *testing.T) {
+		subject := uuid.NewV4().String()
+		code, err := s.Issue("verify-email", subject, "")
+		require.NoError(t, err)
+
+		_, err = s.Consume("verify-email", subject, "wrong")
+		assert.Equal(t, ErrInvalid, err)
+		_, err = s.Consume("verify-email", subject, "wrong")
+		assert.Equal(t, ErrInvalid, err)
+
+		_, err = s.Consume("verify-email", subject, code)
+		assert.Equal(t, ErrTooManyAttempts, err)
+
+		_, err = s.Issue("verify-email", subject, "")
+		assert.Equal(t, ErrTooManyAttempts, err)
+	})
+
+	T.Run("Reissued code", func(t *testing.T) {
+		subject := uuid.NewV4().String()
+		previous, err := s.Issue("reset-password", subject, "old")
+		require.NoError(t, err)
+		code, err := s.Issue("reset-password", subject, "new")
+		require.NoError(t, err)
+
+		_, err = s.Consume("reset-password", subject, previous)
+		assert.Equal(t, ErrInvalid, err)
+		value, err := s.Consume("reset-password", subject, code)
+		require.NoError(t, err)
+		assert.Equal(t, "new", value)
+	})
+
+	T.Run("Subjects can not forge keys", func(t *testing.T) {
+		subject := uuid.NewV4().String()
+		forged, err := s.Issue("verify-email", subject+":attempts", "")
+		require.NoError(t, err)
+
+		// Failed attempts of subject do not touch the code of the other subject
+		_, err = s.Consume("verify-email", subject, "wrong")
+		assert.Equal(t, ErrInvalid, err)
+		_, err = s.Consume("verify-email", subject+":attempts", forged)
+		assert.NoError(t, err)
+	})
+}
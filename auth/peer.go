@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+// PeerIdentity returns SPIFFE ID of given client certificate,
+// or its common name if the certificate has no SPIFFE ID
+func PeerIdentity(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return cert.Subject.CommonName
+}
+
+// PeerRule maps client certificate identities to a service provider.
+//
+// Pattern matches a SPIFFE ID or common name exactly, or as a prefix
+// when it ends with "*", e.g. "spiffe://example.org/ns/prod/*".
+type PeerRule struct {
+	Pattern  string
+	Provider ServiceProviderClaim
+}
+
+// PeerRules is a list of rules, evaluated in order
+type PeerRules []PeerRule
+
+// Match returns provider of the first rule matching given identity
+func (rules PeerRules) Match(identity string) (ServiceProviderClaim, bool) {
+	if identity == "" {
+		return ServiceProviderClaim{}, false
+	}
+	for _, r := range rules {
+		if strings.HasSuffix(r.Pattern, "*") {
+			if strings.HasPrefix(identity, strings.TrimSuffix(r.Pattern, "*")) {
+				return r.Provider, true
+			}
+			continue
+		}
+		if r.Pattern == identity {
+			return r.Provider, true
+		}
+	}
+	return ServiceProviderClaim{}, false
+}
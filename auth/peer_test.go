@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerIdentity(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	assert.Equal(t, "billing", PeerIdentity(cert))
+
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	cert.URIs = []*url.URL{spiffeID}
+	assert.Equal(t, "spiffe://example.org/ns/prod/sa/billing", PeerIdentity(cert))
+}
+
+func TestPeerRules(t *testing.T) {
+	billing := ServiceProviderClaim{ID: "1", Codename: "billing"}
+	prod := ServiceProviderClaim{ID: "2", Codename: "prod"}
+	rules := PeerRules{
+		{Pattern: "spiffe://example.org/ns/prod/sa/billing", Provider: billing},
+		{Pattern: "spiffe://example.org/ns/prod/*", Provider: prod},
+	}
+
+	p, ok := rules.Match("spiffe://example.org/ns/prod/sa/billing")
+	assert.True(t, ok)
+	assert.Equal(t, billing, p)
+
+	p, ok = rules.Match("spiffe://example.org/ns/prod/sa/order")
+	assert.True(t, ok)
+	assert.Equal(t, prod, p)
+
+	_, ok = rules.Match("spiffe://example.org/ns/dev/sa/order")
+	assert.False(t, ok)
+
+	_, ok = rules.Match("")
+	assert.False(t, ok)
+}
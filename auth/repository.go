@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// ErrTokenExists returns an error indicate that
+// the token key is already used
+var ErrTokenExists = errors.New("Token already exists")
+
+// TokenRecord is a token as persisted by a TokenRepository
+type TokenRecord struct {
+	Key     string
+	UserID  string
+	Value   string
+	ActorID string
+	Scopes  []string
+	StepUp  bool
+
+	// CreatedAt and LastSeenAt are zero unless sliding expiration is enabled
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+
+	// TTL is the remaining TTL in seconds, -1 means no expiration.
+	// It is ignored by Create.
+	TTL int
+}
+
+// TokenRepository persists tokens of generators. Keys already contain
+// the generator name, so a repository can be shared by many generators.
+type TokenRepository interface {
+	// Create stores new token for ttl seconds, 0 means no expiration.
+	// It returns ErrTokenExists if the key is already used.
+	Create(r TokenRecord, ttl int) error
+
+	// Get returns token stored under given key, or ErrInvalid
+	Get(key string) (TokenRecord, error)
+
+	// Delete removes tokens and all data attached to them
+	Delete(keys ...string) error
+
+	// Rename moves a token with its expiration and all data attached to it
+	// to a new key. It returns ErrInvalid if the token does not exist, or
+	// ErrTokenExists if the new key is already used.
+	Rename(key, newKey string) error
+
+	// Touch resets TTL of a token and records its last seen time
+	Touch(key string, ttl int, createdAt, lastSeenAt time.Time) error
+
+	// MarkStepUp records step-up authentication of a token for ttl
+	// seconds, but never longer than the token itself
+	MarkStepUp(key string, ttl int) error
+
+	// SetInfo updates metadata attached to a token atomically. Only given
+	// fields are changed, fields with empty value are removed.
+	SetInfo(key string, info map[string]string) error
+	GetInfo(key string) (map[string]string, error)
+
+	// List returns tokens whose key starts with given prefix
+	List(prefix string) ([]TokenRecord, error)
+}
+
+// TokenConsumer is implemented by repositories which can remove a token
+// atomically, so a single use token is accepted at most once
+type TokenConsumer interface {
+	// Consume removes token stored under given key with all data attached
+	// to it and returns the token, or ErrInvalid if it is already removed
+	Consume(key string) (TokenRecord, error)
+}
+
+// ExpiredDeleter is implemented by repositories which do not remove
+// expired tokens by themselves, e.g. SQL and memory repositories
+type ExpiredDeleter interface {
+	// DeleteExpired removes expired tokens and returns how many were removed
+	DeleteExpired() (int, error)
+}
+
+// PurgeExpired returns a function calling repo.DeleteExpired every interval
+// until ctx is done. It can be added to a server with server.RunnableFunc.
+// Errors are logged, so a failed sweep is retried at the next interval.
+func PurgeExpired(repo ExpiredDeleter, interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				n, err := repo.DeleteExpired()
+				if err != nil {
+					ll.Error("Error deleting expired tokens", l.Error(err))
+				} else if n > 0 {
+					ll.Debug("Deleted expired tokens", l.Int("count", n))
+				}
+			}
+		}
+	}
+}
+
+// remainingTTL returns TTL in seconds until expiredAt, -1 if expiredAt is
+// zero, or 0 if it has passed
+func remainingTTL(expiredAt time.Time, now time.Time) int {
+	if expiredAt.IsZero() {
+		return -1
+	}
+	if !expiredAt.After(now) {
+		return 0
+	}
+	// Round up, so a valid token never reports TTL 0
+	return int((expiredAt.Sub(now) + time.Second - 1) / time.Second)
+}
+
+// expiredAt returns the expiration time of given ttl in seconds,
+// zero means no expiration
+func expiredAt(ttl int, now time.Time) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(ttl) * time.Second)
+}
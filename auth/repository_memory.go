@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryToken struct {
+	rec         TokenRecord
+	info        map[string]string
+	expiredAt   time.Time
+	stepUpUntil time.Time
+}
+
+type memoryTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryToken
+}
+
+// NewMemoryTokenRepository returns a repository storing tokens in memory,
+// for tests and single instance services without redis
+func NewMemoryTokenRepository() TokenRepository {
+	return &memoryTokenRepository{
+		tokens: make(map[string]*memoryToken),
+	}
+}
+
+// get returns a live token, expired tokens are removed.
+// It must be called with mu held.
+func (r *memoryTokenRepository) get(key string, now time.Time) (*memoryToken, bool) {
+	t, ok := r.tokens[key]
+	if !ok {
+		return nil, false
+	}
+	if !t.expiredAt.IsZero() && !t.expiredAt.After(now) {
+		delete(r.tokens, key)
+		return nil, false
+	}
+	return t, true
+}
+
+func (t *memoryToken) record(now time.Time) TokenRecord {
+	rec := t.rec
+	rec.TTL = remainingTTL(t.expiredAt, now)
+	rec.StepUp = !t.stepUpUntil.IsZero() && t.stepUpUntil.After(now)
+	return rec
+}
+
+func (r *memoryTokenRepository) Create(rec TokenRecord, ttl int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := r.get(rec.Key, now); ok {
+		return ErrTokenExists
+	}
+	rec.StepUp = false
+	r.tokens[rec.Key] = &memoryToken{
+		rec:       rec,
+		info:      make(map[string]string),
+		expiredAt: expiredAt(ttl, now),
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) Get(key string) (TokenRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	t, ok := r.get(key, now)
+	if !ok {
+		return TokenRecord{}, ErrInvalid
+	}
+	return t.record(now), nil
+}
+
+func (r *memoryTokenRepository) Consume(key string) (TokenRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	t, ok := r.get(key, now)
+	if !ok {
+		return TokenRecord{}, ErrInvalid
+	}
+	delete(r.tokens, key)
+	return t.record(now), nil
+}
+
+func (r *memoryTokenRepository) Delete(keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		delete(r.tokens, key)
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) DeleteExpired() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for key := range r.tokens {
+		if _, ok := r.get(key, now); !ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryTokenRepository) Rename(key, newKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	t, ok := r.get(key, now)
+	if !ok {
+		return ErrInvalid
+	}
+	if _, ok := r.get(newKey, now); ok {
+		return ErrTokenExists
+	}
+	delete(r.tokens, key)
+	t.rec.Key = newKey
+	r.tokens[newKey] = t
+	return nil
+}
+
+func (r *memoryTokenRepository) Touch(key string, ttl int, createdAt, lastSeenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	t, ok := r.get(key, now)
+	if !ok {
+		return ErrInvalid
+	}
+	t.expiredAt = expiredAt(ttl, now)
+	t.rec.CreatedAt, t.rec.LastSeenAt = createdAt, lastSeenAt
+	return nil
+}
+
+func (r *memoryTokenRepository) MarkStepUp(key string, ttl int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	t, ok := r.get(key, now)
+	if !ok {
+		return ErrInvalid
+	}
+	t.stepUpUntil = expiredAt(ttl, now)
+	if !t.expiredAt.IsZero() && t.stepUpUntil.After(t.expiredAt) {
+		t.stepUpUntil = t.expiredAt
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) SetInfo(key string, info map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(key, time.Now())
+	if !ok {
+		return ErrInvalid
+	}
+	for k, v := range info {
+		if v == "" {
+			delete(t.info, k)
+		} else {
+			t.info[k] = v
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) GetInfo(key string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(key, time.Now())
+	if !ok {
+		return nil, ErrInvalid
+	}
+	info := make(map[string]string, len(t.info))
+	for k, v := range t.info {
+		info[k] = v
+	}
+	return info, nil
+}
+
+func (r *memoryTokenRepository) List(prefix string) ([]TokenRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var result []TokenRecord
+	for key := range r.tokens {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if t, ok := r.get(key, now); ok {
+			result = append(result, t.record(now))
+		}
+	}
+	return result, nil
+}
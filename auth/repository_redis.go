@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/redis"
+
+	redigo "github.com/garyburd/redigo/redis"
+)
+
+// Suffixes of keys storing data attached to a token
+var redisAttachedSuffixes = []string{":seen", ":stepup", ":info", ":actor"}
+
+// redisCreateScript stores a token with its attached data if the key
+// is not used yet
+const redisCreateScript = `
+local ttl = tonumber(ARGV[2])
+local ok
+if ttl > 0 then
+	ok = redis.call("SET", KEYS[1], ARGV[1], "EX", ttl, "NX")
+else
+	ok = redis.call("SET", KEYS[1], ARGV[1], "NX")
+end
+if not ok then
+	return 0
+end
+for i = 2, 3 do
+	local v = ARGV[i+1]
+	if v ~= "" then
+		if ttl > 0 then
+			redis.call("SET", KEYS[i], v, "EX", ttl)
+		else
+			redis.call("SET", KEYS[i], v)
+		end
+	end
+end
+return 1
+`
+
+// redisGetScript returns a token with its attached data in one round trip
+const redisGetScript = `
+local v = redis.call("GET", KEYS[1])
+if not v then
+	return false
+end
+return {
+	v,
+	redis.call("TTL", KEYS[1]),
+	redis.call("GET", KEYS[2]) or "",
+	redis.call("EXISTS", KEYS[3]),
+	redis.call("GET", KEYS[4]) or "",
+}
+`
+
+// redisConsumeScript returns a token with its attached data like
+// redisGetScript and deletes them, so only one caller gets the token
+const redisConsumeScript = `
+local v = redis.call("GET", KEYS[1])
+if not v then
+	return false
+end
+local reply = {
+	v,
+	redis.call("TTL", KEYS[1]),
+	redis.call("GET", KEYS[2]) or "",
+	redis.call("EXISTS", KEYS[3]),
+	redis.call("GET", KEYS[4]) or "",
+}
+redis.call("DEL", unpack(KEYS))
+return reply
+`
+
+// redisRenameScript moves a token and its attached data, keys are pairs of
+// the old and new key. It returns 0 if the token does not exist, or -1 if
+// the new key is already used.
+const redisRenameScript = `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return -1
+end
+for i = 1, #KEYS, 2 do
+	if redis.call("EXISTS", KEYS[i]) == 1 then
+		redis.call("RENAME", KEYS[i], KEYS[i+1])
+	else
+		redis.call("DEL", KEYS[i+1])
+	end
+end
+return 1
+`
+
+// redisTouchScript resets TTL of a token and its attached data
+const redisTouchScript = `
+if redis.call("EXPIRE", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("SET", KEYS[2], ARGV[2], "EX", ARGV[1])
+redis.call("EXPIRE", KEYS[3], ARGV[1])
+redis.call("EXPIRE", KEYS[4], ARGV[1])
+return 1
+`
+
+// redisSetInfoScript updates fields of the info hash of a token, keeping
+// the remaining TTL of the token. Empty values remove fields.
+const redisSetInfoScript = `
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -2 then
+	return 0
+end
+for i = 1, #ARGV, 2 do
+	if ARGV[i+1] == "" then
+		redis.call("HDEL", KEYS[2], ARGV[i])
+	else
+		redis.call("HSET", KEYS[2], ARGV[i], ARGV[i+1])
+	end
+end
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[2], ttl)
+end
+return 1
+`
+
+// redisGetInfoScript returns the info hash of a token, or false if the
+// token does not exist
+const redisGetInfoScript = `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+return redis.call("HGETALL", KEYS[2])
+`
+
+type redisTokenRepository struct {
+	redisStore redis.AtomicStore
+}
+
+// NewRedisTokenRepository returns a repository storing tokens in redis.
+//
+// A token is stored as "userID:value" under its key, attached data is
+// stored under the key with suffixes :seen, :stepup, :info and :actor.
+func NewRedisTokenRepository(r redis.Store) TokenRepository {
+	return &redisTokenRepository{redisStore: atomicStore(r)}
+}
+
+func (r *redisTokenRepository) Create(rec TokenRecord, ttl int) error {
+	seen := ""
+	if !rec.CreatedAt.IsZero() {
+		seen = formatSeen(rec.CreatedAt, rec.LastSeenAt)
+	}
+	actor := ""
+	if rec.ActorID != "" {
+		actor = rec.ActorID + ":" + strings.Join(rec.Scopes, " ")
+	}
+
+	keys := []string{rec.Key, rec.Key + ":seen", rec.Key + ":actor"}
+	ok, err := redigo.Int(r.redisStore.Eval(redisCreateScript, keys, rec.UserID+":"+rec.Value, ttl, seen, actor))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrTokenExists
+	}
+	return nil
+}
+
+func (r *redisTokenRepository) Get(key string) (TokenRecord, error) {
+	keys := []string{key, key + ":seen", key + ":stepup", key + ":actor"}
+	return r.record(key, redisGetScript, keys)
+}
+
+func (r *redisTokenRepository) Consume(key string) (TokenRecord, error) {
+	keys := []string{key, key + ":seen", key + ":stepup", key + ":actor", key + ":info"}
+	return r.record(key, redisConsumeScript, keys)
+}
+
+// record parses the reply of redisGetScript or redisConsumeScript
+func (r *redisTokenRepository) record(key, script string, keys []string) (TokenRecord, error) {
+	reply, err := redigo.Values(r.redisStore.Eval(script, keys))
+	if err == redigo.ErrNil {
+		return TokenRecord{}, ErrInvalid
+	}
+	if err != nil {
+		return TokenRecord{}, err
+	}
+
+	var (
+		value, seen, actor string
+		ttl, stepUp        int
+	)
+	if _, err := redigo.Scan(reply, &value, &ttl, &seen, &stepUp, &actor); err != nil {
+		return TokenRecord{}, err
+	}
+
+	rec := TokenRecord{
+		Key:    key,
+		TTL:    ttl,
+		StepUp: stepUp == 1,
+	}
+	s := strings.SplitN(value, ":", 2)
+	rec.UserID = s[0]
+	if len(s) > 1 {
+		rec.Value = s[1]
+	}
+	rec.CreatedAt, rec.LastSeenAt, _ = parseSeen(seen)
+	if s := strings.SplitN(actor, ":", 2); len(s) == 2 {
+		rec.ActorID = s[0]
+		rec.Scopes = strings.Fields(s[1])
+	}
+	return rec, nil
+}
+
+func (r *redisTokenRepository) Delete(keys ...string) error {
+	all := make([]string, 0, len(keys)*(len(redisAttachedSuffixes)+1))
+	for _, key := range keys {
+		all = append(all, key)
+		for _, suffix := range redisAttachedSuffixes {
+			all = append(all, key+suffix)
+		}
+	}
+	return r.redisStore.Del(all...)
+}
+
+func (r *redisTokenRepository) Rename(key, newKey string) error {
+	keys := []string{key, newKey}
+	for _, suffix := range redisAttachedSuffixes {
+		keys = append(keys, key+suffix, newKey+suffix)
+	}
+	ok, err := redigo.Int(r.redisStore.Eval(redisRenameScript, keys))
+	if err != nil {
+		return err
+	}
+	switch ok {
+	case 0:
+		return ErrInvalid
+	case -1:
+		return ErrTokenExists
+	}
+	return nil
+}
+
+func (r *redisTokenRepository) Touch(key string, ttl int, createdAt, lastSeenAt time.Time) error {
+	keys := []string{key, key + ":seen", key + ":info", key + ":actor"}
+	ok, err := redigo.Int(r.redisStore.Eval(redisTouchScript, keys, ttl, formatSeen(createdAt, lastSeenAt)))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (r *redisTokenRepository) MarkStepUp(key string, ttl int) error {
+	tokenTTL, err := r.redisStore.GetTTL(key)
+	if err != nil {
+		return err
+	}
+	if tokenTTL == -2 {
+		return ErrInvalid
+	}
+	if tokenTTL > 0 && tokenTTL < ttl {
+		ttl = tokenTTL
+	}
+	return r.redisStore.SetStringWithTTL(key+":stepup", "1", ttl)
+}
+
+func (r *redisTokenRepository) SetInfo(key string, info map[string]string) error {
+	args := make([]interface{}, 0, len(info)*2)
+	for k, v := range info {
+		args = append(args, k, v)
+	}
+	ok, err := redigo.Int(r.redisStore.Eval(redisSetInfoScript, []string{key, key + ":info"}, args...))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (r *redisTokenRepository) GetInfo(key string) (map[string]string, error) {
+	reply, err := r.redisStore.Eval(redisGetInfoScript, []string{key, key + ":info"})
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrInvalid
+	}
+	return redigo.StringMap(reply, nil)
+}
+
+func (r *redisTokenRepository) List(prefix string) ([]TokenRecord, error) {
+	keys, err := r.redisStore.GetStrings(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TokenRecord
+	for _, key := range keys {
+		if isAttachedKey(key) {
+			continue
+		}
+		rec, err := r.Get(key)
+		if err != nil {
+			// The token may be expired or revoked in the meantime
+			continue
+		}
+		result = append(result, rec)
+	}
+	return result, nil
+}
+
+func isAttachedKey(key string) bool {
+	for _, suffix := range redisAttachedSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSeen(createdAt, lastSeenAt time.Time) string {
+	return strconv.FormatInt(createdAt.Unix(), 10) + ":" + strconv.FormatInt(lastSeenAt.Unix(), 10)
+}
+
+func parseSeen(value string) (createdAt, lastSeenAt time.Time, ok bool) {
+	s := strings.Split(value, ":")
+	if len(s) != 2 {
+		return
+	}
+	created, err1 := strconv.ParseInt(s[0], 10, 64)
+	seen, err2 := strconv.ParseInt(s[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	return time.Unix(created, 0), time.Unix(seen, 0), true
+}
@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTable is used when no table name is provided
+const DefaultTokenTable = "auth_tokens"
+
+// SQLTokenSchema returns DDL of the token table. Times are stored as unix
+// seconds, 0 means no expiration.
+func SQLTokenSchema(table string) string {
+	if table == "" {
+		table = DefaultTokenTable
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	token_key     VARCHAR(255) NOT NULL PRIMARY KEY,
+	user_id       VARCHAR(255) NOT NULL,
+	value         TEXT         NOT NULL,
+	actor_id      VARCHAR(255) NOT NULL DEFAULT '',
+	scopes        TEXT         NOT NULL,
+	info          TEXT         NOT NULL,
+	step_up_until BIGINT       NOT NULL DEFAULT 0,
+	created_at    BIGINT       NOT NULL DEFAULT 0,
+	last_seen_at  BIGINT       NOT NULL DEFAULT 0,
+	expired_at    BIGINT       NOT NULL DEFAULT 0
+)`, table)
+}
+
+type sqlTokenRepository struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLTokenRepository returns a repository storing tokens in given table,
+// which can be created with SQLTokenSchema. Queries use `?` placeholders,
+// as supported by SQLite and MySQL drivers.
+func NewSQLTokenRepository(db *sql.DB, table string) TokenRepository {
+	if table == "" {
+		table = DefaultTokenTable
+	}
+	return &sqlTokenRepository{
+		db:    db,
+		table: table,
+	}
+}
+
+const sqlTokenColumns = "token_key, user_id, value, actor_id, scopes, step_up_until, created_at, last_seen_at, expired_at"
+
+// live is the condition selecting tokens which are not expired
+const sqlLive = "(expired_at = 0 OR expired_at > ?)"
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+type sqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTokenRecord(row sqlScanner, now time.Time) (TokenRecord, error) {
+	var (
+		rec                                         TokenRecord
+		scopes                                      string
+		stepUpUntil, createdAt, lastSeenAt, expired int64
+	)
+	err := row.Scan(&rec.Key, &rec.UserID, &rec.Value, &rec.ActorID, &scopes, &stepUpUntil, &createdAt, &lastSeenAt, &expired)
+	if err != nil {
+		return rec, err
+	}
+
+	rec.Scopes = strings.Fields(scopes)
+	rec.StepUp = stepUpUntil > now.Unix()
+	rec.CreatedAt = timeOrZero(createdAt)
+	rec.LastSeenAt = timeOrZero(lastSeenAt)
+	rec.TTL = remainingTTL(timeOrZero(expired), now)
+	return rec, nil
+}
+
+func (r *sqlTokenRepository) Create(rec TokenRecord, ttl int) (_err error) {
+	now := time.Now()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Expired tokens are removed by DeleteExpired, the key may be reused before
+	_, err = tx.Exec("DELETE FROM "+r.table+" WHERE token_key = ? AND NOT "+sqlLive, rec.Key, now.Unix())
+	if err != nil {
+		return err
+	}
+
+	var n int
+	err = tx.QueryRow("SELECT COUNT(*) FROM "+r.table+" WHERE token_key = ?", rec.Key).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrTokenExists
+	}
+
+	_, err = tx.Exec("INSERT INTO "+r.table+" ("+sqlTokenColumns+", info) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		rec.Key, rec.UserID, rec.Value, rec.ActorID, strings.Join(rec.Scopes, " "),
+		0, unixOrZero(rec.CreatedAt), unixOrZero(rec.LastSeenAt), unixOrZero(expiredAt(ttl, now)), "{}")
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlTokenRepository) Get(key string) (TokenRecord, error) {
+	now := time.Now()
+	row := r.db.QueryRow("SELECT "+sqlTokenColumns+" FROM "+r.table+" WHERE token_key = ? AND "+sqlLive, key, now.Unix())
+	rec, err := scanTokenRecord(row, now)
+	if err == sql.ErrNoRows {
+		return TokenRecord{}, ErrInvalid
+	}
+	return rec, err
+}
+
+func (r *sqlTokenRepository) Consume(key string) (TokenRecord, error) {
+	rec, err := r.Get(key)
+	if err != nil {
+		return rec, err
+	}
+
+	// Only the caller actually deleting the row consumes the token
+	res, err := r.db.Exec("DELETE FROM "+r.table+" WHERE token_key = ? AND "+sqlLive, key, time.Now().Unix())
+	if err != nil {
+		return TokenRecord{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return TokenRecord{}, err
+	}
+	if n == 0 {
+		return TokenRecord{}, ErrInvalid
+	}
+	return rec, nil
+}
+
+func (r *sqlTokenRepository) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	_, err := r.db.Exec("DELETE FROM "+r.table+" WHERE token_key IN ("+placeholders+")", args...)
+	return err
+}
+
+func (r *sqlTokenRepository) DeleteExpired() (int, error) {
+	res, err := r.db.Exec("DELETE FROM "+r.table+" WHERE NOT "+sqlLive, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *sqlTokenRepository) Rename(key, newKey string) (_err error) {
+	now := time.Now()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM "+r.table+" WHERE token_key = ? AND NOT "+sqlLive, newKey, now.Unix())
+	if err != nil {
+		return err
+	}
+
+	var n int
+	err = tx.QueryRow("SELECT COUNT(*) FROM "+r.table+" WHERE token_key = ?", newKey).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrTokenExists
+	}
+
+	res, err := tx.Exec("UPDATE "+r.table+" SET token_key = ? WHERE token_key = ? AND "+sqlLive, newKey, key, now.Unix())
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalid
+	}
+	return tx.Commit()
+}
+
+func (r *sqlTokenRepository) update(key string, set string, args ...interface{}) error {
+	args = append(args, key, time.Now().Unix())
+	res, err := r.db.Exec("UPDATE "+r.table+" SET "+set+" WHERE token_key = ? AND "+sqlLive, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (r *sqlTokenRepository) Touch(key string, ttl int, createdAt, lastSeenAt time.Time) error {
+	return r.update(key, "expired_at = ?, created_at = ?, last_seen_at = ?",
+		unixOrZero(expiredAt(ttl, time.Now())), unixOrZero(createdAt), unixOrZero(lastSeenAt))
+}
+
+func (r *sqlTokenRepository) MarkStepUp(key string, ttl int) error {
+	until := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+	return r.update(key, "step_up_until = CASE WHEN expired_at > 0 AND expired_at < ? THEN expired_at ELSE ? END", until, until)
+}
+
+func (r *sqlTokenRepository) SetInfo(key string, info map[string]string) error {
+	// Compare and swap, so concurrent updates of other fields are not lost
+	for {
+		var data string
+		err := r.db.QueryRow("SELECT info FROM "+r.table+" WHERE token_key = ? AND "+sqlLive, key, time.Now().Unix()).Scan(&data)
+		if err == sql.ErrNoRows {
+			return ErrInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		stored := make(map[string]string)
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return err
+		}
+		for k, v := range info {
+			if v == "" {
+				delete(stored, k)
+			} else {
+				stored[k] = v
+			}
+		}
+		b, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+
+		// MySQL reports no affected rows when the value is unchanged
+		if string(b) == data {
+			return nil
+		}
+
+		res, err := r.db.Exec("UPDATE "+r.table+" SET info = ? WHERE token_key = ? AND info = ?", string(b), key, data)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		// The info is changed or the token is removed meanwhile, try again
+	}
+}
+
+func (r *sqlTokenRepository) GetInfo(key string) (map[string]string, error) {
+	var data string
+	err := r.db.QueryRow("SELECT info FROM "+r.table+" WHERE token_key = ? AND "+sqlLive, key, time.Now().Unix()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := make(map[string]string)
+	err = json.Unmarshal([]byte(data), &info)
+	return info, err
+}
+
+func (r *sqlTokenRepository) List(prefix string) ([]TokenRecord, error) {
+	now := time.Now()
+	rows, err := r.db.Query("SELECT "+sqlTokenColumns+" FROM "+r.table+" WHERE token_key LIKE ? ESCAPE '\\' AND "+sqlLive,
+		escapeLike(prefix)+"%", now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []TokenRecord
+	for rows.Next() {
+		rec, err := scanTokenRecord(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rec)
+	}
+	return result, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,367 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteRepository(t *testing.T) (TokenRepository, *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(SQLTokenSchema(""))
+	require.NoError(t, err)
+	return NewSQLTokenRepository(db, ""), db
+}
+
+// testRepositories returns repositories to test and a func closing them,
+// which must be called when the test is done
+func testRepositories(t *testing.T) (map[string]TokenRepository, func()) {
+	sqlRepo, db := newSQLiteRepository(t)
+	return map[string]TokenRepository{
+		"redis":  NewRedisTokenRepository(rStore),
+		"memory": NewMemoryTokenRepository(),
+		"sql":    sqlRepo,
+	}, func() { db.Close() }
+}
+
+func TestTokenRepository(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		repo := repo
+		prefix := "repo-" + name + ":"
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(time.Now().Unix(), 0)
+			rec := TokenRecord{
+				Key:        prefix + "a",
+				UserID:     "u1",
+				Value:      "v1",
+				ActorID:    "admin",
+				Scopes:     []string{"read", "write"},
+				CreatedAt:  now,
+				LastSeenAt: now,
+			}
+			require.NoError(t, repo.Create(rec, 100))
+			assert.Equal(t, ErrTokenExists, repo.Create(rec, 100))
+
+			got, err := repo.Get(rec.Key)
+			require.NoError(t, err)
+			assert.Equal(t, "u1", got.UserID)
+			assert.Equal(t, "v1", got.Value)
+			assert.Equal(t, "admin", got.ActorID)
+			assert.Equal(t, []string{"read", "write"}, got.Scopes)
+			assert.True(t, got.CreatedAt.Equal(now))
+			assert.False(t, got.StepUp)
+			assert.True(t, got.TTL > 90 && got.TTL <= 100, "ttl %v", got.TTL)
+
+			_, err = repo.Get(prefix + "missing")
+			assert.Equal(t, ErrInvalid, err)
+
+			// Step-up never outlives the token
+			require.NoError(t, repo.MarkStepUp(rec.Key, 1000))
+			got, err = repo.Get(rec.Key)
+			require.NoError(t, err)
+			assert.True(t, got.StepUp)
+			assert.Equal(t, ErrInvalid, repo.MarkStepUp(prefix+"missing", 10))
+
+			later := now.Add(10 * time.Second)
+			require.NoError(t, repo.Touch(rec.Key, 500, now, later))
+			got, err = repo.Get(rec.Key)
+			require.NoError(t, err)
+			assert.True(t, got.LastSeenAt.Equal(later))
+			assert.True(t, got.TTL > 490, "ttl %v", got.TTL)
+
+			require.NoError(t, repo.SetInfo(rec.Key, map[string]string{"ip": "1.2.3.4", "ua": "curl"}))
+			require.NoError(t, repo.SetInfo(rec.Key, map[string]string{"ua": ""}))
+			info, err := repo.GetInfo(rec.Key)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"ip": "1.2.3.4"}, info)
+			assert.Equal(t, ErrInvalid, repo.SetInfo(prefix+"missing", map[string]string{"ip": "1"}))
+			_, err = repo.GetInfo(prefix + "missing")
+			assert.Equal(t, ErrInvalid, err)
+
+			require.NoError(t, repo.Create(TokenRecord{Key: prefix + "b", UserID: "u2"}, 0))
+			recs, err := repo.List(prefix)
+			require.NoError(t, err)
+			var keys []string
+			for _, r := range recs {
+				keys = append(keys, r.Key)
+				if r.Key == prefix+"b" {
+					assert.Equal(t, -1, r.TTL)
+				}
+			}
+			sort.Strings(keys)
+			assert.Equal(t, []string{prefix + "a", prefix + "b"}, keys)
+
+			require.NoError(t, repo.Delete(prefix+"a", prefix+"b"))
+			_, err = repo.Get(rec.Key)
+			assert.Equal(t, ErrInvalid, err)
+			_, err = repo.GetInfo(rec.Key)
+			assert.Equal(t, ErrInvalid, err)
+
+			// A deleted key can be used again, without data of the old token
+			require.NoError(t, repo.Create(TokenRecord{Key: rec.Key, UserID: "u3"}, 100))
+			got, err = repo.Get(rec.Key)
+			require.NoError(t, err)
+			assert.Equal(t, "u3", got.UserID)
+			assert.Equal(t, "", got.ActorID)
+			assert.False(t, got.StepUp)
+			require.NoError(t, repo.Delete(rec.Key))
+		})
+	}
+}
+
+func TestTokenRepositoryExpiration(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		if name == "redis" {
+			continue
+		}
+		repo := repo
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(TokenRecord{Key: "exp:a", UserID: "u1"}, 1))
+			time.Sleep(2 * time.Second)
+
+			_, err := repo.Get("exp:a")
+			assert.Equal(t, ErrInvalid, err)
+			recs, err := repo.List("exp:")
+			require.NoError(t, err)
+			assert.Empty(t, recs)
+
+			// Expired keys can be used again
+			require.NoError(t, repo.Create(TokenRecord{Key: "exp:a", UserID: "u2"}, 10))
+		})
+	}
+}
+
+func TestTokenRepositoryDeleteExpired(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		deleter, ok := repo.(ExpiredDeleter)
+		if !ok {
+			continue
+		}
+		repo := repo
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(TokenRecord{Key: "purge:a", UserID: "u1"}, 1))
+			require.NoError(t, repo.Create(TokenRecord{Key: "purge:b", UserID: "u2"}, 100))
+			require.NoError(t, repo.Create(TokenRecord{Key: "purge:c", UserID: "u3"}, 0))
+			time.Sleep(2 * time.Second)
+
+			n, err := deleter.DeleteExpired()
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			n, err = deleter.DeleteExpired()
+			require.NoError(t, err)
+			assert.Equal(t, 0, n)
+
+			recs, err := repo.List("purge:")
+			require.NoError(t, err)
+			assert.Len(t, recs, 2)
+		})
+	}
+}
+
+type countDeleter struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (d *countDeleter) DeleteExpired() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls++
+	return 0, errors.New("database is locked")
+}
+
+func TestPurgeExpired(t *testing.T) {
+	d := &countDeleter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- PurgeExpired(d, 5*time.Millisecond)(ctx) }()
+
+	// Errors do not stop the sweep
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("PurgeExpired did not return")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	assert.True(t, d.calls >= 2, "calls %v", d.calls)
+}
+
+func TestTokenRepositoryRename(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		repo := repo
+		prefix := "rename-" + name + ":"
+		t.Run(name, func(t *testing.T) {
+			rec := TokenRecord{Key: prefix + "a", UserID: "u1", ActorID: "admin", Scopes: []string{"read"}}
+			require.NoError(t, repo.Create(rec, 100))
+			require.NoError(t, repo.SetInfo(rec.Key, map[string]string{"ip": "1.2.3.4"}))
+			require.NoError(t, repo.MarkStepUp(rec.Key, 60))
+
+			require.NoError(t, repo.Rename(rec.Key, prefix+"b"))
+			_, err := repo.Get(rec.Key)
+			assert.Equal(t, ErrInvalid, err)
+
+			got, err := repo.Get(prefix + "b")
+			require.NoError(t, err)
+			assert.Equal(t, prefix+"b", got.Key)
+			assert.Equal(t, "u1", got.UserID)
+			assert.Equal(t, "admin", got.ActorID)
+			assert.True(t, got.StepUp)
+			assert.True(t, got.TTL > 90 && got.TTL <= 100, "ttl %v", got.TTL)
+			info, err := repo.GetInfo(prefix + "b")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"ip": "1.2.3.4"}, info)
+
+			assert.Equal(t, ErrInvalid, repo.Rename(rec.Key, prefix+"c"))
+			require.NoError(t, repo.Create(TokenRecord{Key: prefix + "c", UserID: "u2"}, 100))
+			assert.Equal(t, ErrTokenExists, repo.Rename(prefix+"b", prefix+"c"))
+			require.NoError(t, repo.Delete(prefix+"b", prefix+"c"))
+		})
+	}
+}
+
+func TestTokenRepositoryConcurrentSetInfo(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		repo := repo
+		key := "setinfo-" + name + ":a"
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(TokenRecord{Key: key, UserID: "u1"}, 100))
+			defer repo.Delete(key)
+
+			// Updates of different fields are not lost
+			var wg sync.WaitGroup
+			expected := make(map[string]string)
+			for i := 0; i < 10; i++ {
+				field := "f" + strconv.Itoa(i)
+				expected[field] = "1"
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, repo.SetInfo(key, map[string]string{field: "1"}))
+				}()
+			}
+			wg.Wait()
+
+			info, err := repo.GetInfo(key)
+			require.NoError(t, err)
+			assert.Equal(t, expected, info)
+		})
+	}
+}
+
+func TestTokenRepositoryConsume(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		repo := repo
+		key := "consume-" + name + ":a"
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(TokenRecord{Key: key, UserID: "u1", Value: "v1"}, 100))
+			require.NoError(t, repo.SetInfo(key, map[string]string{"ip": "1.2.3.4"}))
+
+			// Only one of concurrent consumers gets the token
+			var wg sync.WaitGroup
+			var consumed int32
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rec, err := repo.(TokenConsumer).Consume(key)
+					if err == nil {
+						assert.Equal(t, "u1", rec.UserID)
+						assert.Equal(t, "v1", rec.Value)
+						atomic.AddInt32(&consumed, 1)
+					} else {
+						assert.Equal(t, ErrInvalid, err)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), consumed)
+
+			_, err := repo.Get(key)
+			assert.Equal(t, ErrInvalid, err)
+			_, err = repo.GetInfo(key)
+			assert.Equal(t, ErrInvalid, err)
+		})
+	}
+}
+
+func TestGeneratorWithRepository(t *testing.T) {
+	repos, closeRepos := testRepositories(t)
+	defer closeRepos()
+	for name, repo := range repos {
+		repo := repo
+		t.Run(name, func(t *testing.T) {
+			g := NewGeneratorWithRepository("gen-"+name, repo,
+				WithHashedKeys([]byte("secret")), WithStepUp(), WithDelegation())
+
+			tok, err := g.GenerateWithValue("u1", "v1", 100)
+			require.NoError(t, err)
+
+			v, err := g.Validate(tok.TokenStr)
+			require.NoError(t, err)
+			assert.Equal(t, "u1", v.UserID)
+			assert.Equal(t, "v1", v.Value)
+			assert.False(t, v.StepUp)
+
+			require.NoError(t, g.(StepUpMarker).MarkStepUp(tok.TokenStr, 60))
+			v, err = g.Validate(tok.TokenStr)
+			require.NoError(t, err)
+			assert.True(t, v.StepUp)
+
+			d, err := g.(Delegator).GenerateDelegated("admin", "u1", []string{"read"}, 0)
+			require.NoError(t, err)
+			v, err = g.Validate(d.TokenStr)
+			require.NoError(t, err)
+			assert.Equal(t, "admin", v.ActorID)
+			assert.True(t, v.HasScope("read"))
+			assert.False(t, v.HasScope("write"))
+
+			infos, err := g.(Inspector).ListTokens("u1")
+			require.NoError(t, err)
+			assert.Len(t, infos, 2)
+
+			n, err := g.(Inspector).RevokeAllForUser("u1")
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+			_, err = g.Validate(tok.TokenStr)
+			assert.Equal(t, ErrInvalid, err)
+
+			code, err := g.GenerateWithValue("u2", "code", 100)
+			require.NoError(t, err)
+			v, err = g.(Consumer).Consume(code.TokenStr)
+			require.NoError(t, err)
+			assert.Equal(t, "u2", v.UserID)
+			assert.Equal(t, "code", v.Value)
+			_, err = g.(Consumer).Consume(code.TokenStr)
+			assert.Equal(t, ErrInvalid, err)
+		})
+	}
+}
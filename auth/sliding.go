@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// SlidingExpiration configures tokens which stay valid as long as they are used.
+//
+// Once a token is validated, its TTL is reset to TTL seconds, so the TTL
+// passed to Generate only applies until the first use of the token.
+type SlidingExpiration struct {
+	// TTL in seconds since the last use of the token
+	TTL int
+
+	// MaxLifetime in seconds since the token was generated, 0 means no limit
+	MaxLifetime int
+
+	// Throttle is the minimum interval in seconds between two extensions,
+	// so Validate does not write to the repository on every request. It
+	// must be less than TTL.
+	Throttle int
+}
+
+// WithSlidingExpiration extends token TTL and records last seen time on Validate
+func WithSlidingExpiration(s SlidingExpiration) GeneratorOption {
+	if s.TTL <= 0 {
+		panic("auth: sliding expiration requires a positive TTL")
+	}
+	if s.Throttle >= s.TTL {
+		// Tokens used more often than Throttle would never be extended
+		panic("auth: sliding expiration throttle must be less than TTL")
+	}
+	return func(g *generator) {
+		g.sliding = &s
+	}
+}
+
+// lifetimeTTL bounds the given ttl by the remaining lifetime of the token.
+// It returns 0 when the token has outlived MaxLifetime.
+func (g *generator) lifetimeTTL(createdAt time.Time, ttl int) int {
+	if g.sliding.MaxLifetime <= 0 {
+		return ttl
+	}
+
+	remaining := int(createdAt.Unix() + int64(g.sliding.MaxLifetime) - time.Now().Unix())
+	if remaining <= 0 {
+		return 0
+	}
+	if remaining < ttl {
+		return remaining
+	}
+	return ttl
+}
+
+// touch extends TTL of a validated token and records last seen time
+func (g *generator) touch(t Token) (Token, error) {
+	now := time.Now()
+	ok := !t.CreatedAt.IsZero()
+	if !ok {
+		// The token was generated before sliding expiration was enabled
+		t.CreatedAt = now
+	}
+
+	throttle := time.Duration(g.sliding.Throttle) * time.Second
+	if ok && now.Sub(t.LastSeenAt) < throttle {
+		return t, nil
+	}
+
+	ttl := g.lifetimeTTL(t.CreatedAt, g.sliding.TTL)
+	if ttl == 0 {
+		_ = g.Revoke(t.TokenStr)
+		return t, ErrInvalid
+	}
+
+	t.LastSeenAt = now
+	if err := g.repo.Touch(g.toKey(t), ttl, t.CreatedAt, t.LastSeenAt); err != nil {
+		ll.Error("Error extending token", l.String("subject", t.SubjectID), l.Error(err))
+	}
+	return t, nil
+}
@@ -0,0 +1,25 @@
+package auth
+
+// WithStepUp populates Token.StepUp on Validate, for tokens marked with
+// MarkStepUp
+func WithStepUp() GeneratorOption {
+	return func(g *generator) {
+		g.stepUp = true
+	}
+}
+
+// StepUpMarker is implemented by generators recording step-up
+// authentication of tokens
+type StepUpMarker interface {
+	MarkStepUp(tokenStr string, ttl int) error
+}
+
+// MarkStepUp records that given token passed step-up authentication.
+// The mark expires after ttl seconds, but never outlives the token.
+func (g *generator) MarkStepUp(tokenStr string, ttl int) error {
+	t := Token{
+		TokenStr:  tokenStr,
+		SubjectID: g.name,
+	}
+	return g.repo.MarkStepUp(g.toKey(t), ttl)
+}
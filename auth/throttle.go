@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/redis"
+)
+
+// Security event types
+const (
+	EventAuthFailed = "auth_failed"
+	EventBanned     = "banned"
+	EventBlocked    = "blocked"
+
+	// EventSuspiciousPrefix is emitted when tokens sharing a prefix failed
+	// MaxFailures times, see ThrottleConfig.TokenPrefixLength
+	EventSuspiciousPrefix = "suspicious_prefix"
+)
+
+// Default values of ThrottleConfig
+const (
+	DefaultThrottleWindow      = 10 * 60 // In seconds
+	DefaultThrottleMaxFailures = 20
+	DefaultThrottleBanTTL      = 30 * 60 // In seconds
+	DefaultThrottleDelayAfter  = 3
+	DefaultThrottleBaseDelay   = 100 * time.Millisecond
+	DefaultThrottleMaxDelay    = 5 * time.Second
+)
+
+// ErrBanned returns an error indicate that the client is temporarily banned
+// after too many failed authentications
+var ErrBanned = errors.New("Too many failed authentications")
+
+// SecurityEvent describes a suspicious authentication activity
+type SecurityEvent struct {
+	Type string
+	IP   string
+
+	// Token is the Fingerprint of the token
+	Token    string
+	Failures int
+	Time     time.Time
+}
+
+// ThrottleConfig configures brute-force protection. Failures are counted
+// per client IP, and per token prefix when TokenPrefixLength is set.
+type ThrottleConfig struct {
+	// Window in seconds in which failures are counted
+	Window int
+
+	// MaxFailures in a window before the client is banned for BanTTL seconds
+	MaxFailures int
+	BanTTL      int
+
+	// Requests are delayed after DelayAfter failures, doubling from
+	// BaseDelay up to MaxDelay
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	// TokenPrefixLength enables counting failures of tokens sharing their
+	// first TokenPrefixLength characters, e.g. credential stuffing from many
+	// IPs. As invalid tokens do not identify their sender, these failures
+	// only delay requests and emit EventSuspiciousPrefix, they never ban.
+	// Tokens with a fixed prefix, e.g. JWTs, must not be tracked.
+	TokenPrefixLength int
+
+	// OnEvent is called for each security event, e.g. to feed a SIEM
+	OnEvent func(SecurityEvent)
+}
+
+// Throttler tracks failed authentications
+type Throttler interface {
+	// Allow returns the delay to apply before validating a token,
+	// or ErrBanned if the client is banned
+	Allow(ip, tokenStr string) (time.Duration, error)
+
+	// Failure records a failed authentication
+	Failure(ip, tokenStr string)
+}
+
+type throttler struct {
+	cfg        ThrottleConfig
+	redisStore redis.AtomicStore
+}
+
+// NewThrottler returns new throttler storing failures in redis
+func NewThrottler(r redis.Store, cfg ThrottleConfig) Throttler {
+	if cfg.Window == 0 {
+		cfg.Window = DefaultThrottleWindow
+	}
+	if cfg.MaxFailures == 0 {
+		cfg.MaxFailures = DefaultThrottleMaxFailures
+	}
+	if cfg.BanTTL == 0 {
+		cfg.BanTTL = DefaultThrottleBanTTL
+	}
+	if cfg.DelayAfter == 0 {
+		cfg.DelayAfter = DefaultThrottleDelayAfter
+	}
+	if cfg.BaseDelay == 0 {
+		cfg.BaseDelay = DefaultThrottleBaseDelay
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = DefaultThrottleMaxDelay
+	}
+	return &throttler{
+		cfg:        cfg,
+		redisStore: atomicStore(r),
+	}
+}
+
+// subject is a tracked source of failures
+type subject struct {
+	key string
+
+	// bannable subjects identify the client
+	bannable bool
+}
+
+// subjects returns the tracked subjects, empty ones are skipped
+func (t *throttler) subjects(ip, tokenStr string) []subject {
+	var subjects []subject
+	if ip != "" {
+		subjects = append(subjects, subject{key: "ip:" + ip, bannable: true})
+	}
+	if t.cfg.TokenPrefixLength > 0 && len(tokenStr) > t.cfg.TokenPrefixLength {
+		// Only a fingerprint of the prefix is stored
+		prefix := Fingerprint(tokenStr[:t.cfg.TokenPrefixLength])
+		subjects = append(subjects, subject{key: "prefix:" + prefix})
+	}
+	return subjects
+}
+
+func (t *throttler) emit(typ, ip, tokenStr string, failures int) {
+	if t.cfg.OnEvent == nil {
+		return
+	}
+	t.cfg.OnEvent(SecurityEvent{
+		Type:     typ,
+		IP:       ip,
+		Token:    Fingerprint(tokenStr),
+		Failures: failures,
+		Time:     time.Now(),
+	})
+}
+
+func (t *throttler) Allow(ip, tokenStr string) (time.Duration, error) {
+	failures := 0
+	for _, s := range t.subjects(ip, tokenStr) {
+		if s.bannable && t.redisStore.IsExist("authban:"+s.key) {
+			t.emit(EventBlocked, ip, tokenStr, 0)
+			return 0, ErrBanned
+		}
+
+		n, err := t.redisStore.GetUint64("authfail:" + s.key)
+		if err != nil {
+			// Do not lock everyone out when redis is unavailable
+			ll.Error("Error reading failed authentications", l.Error(err))
+			continue
+		}
+		if int(n) > failures {
+			failures = int(n)
+		}
+	}
+	return t.delay(failures), nil
+}
+
+func (t *throttler) delay(failures int) time.Duration {
+	if failures <= t.cfg.DelayAfter {
+		return 0
+	}
+	d := t.cfg.BaseDelay
+	for i := t.cfg.DelayAfter + 1; i < failures && d < t.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.cfg.MaxDelay {
+		d = t.cfg.MaxDelay
+	}
+	return d
+}
+
+func (t *throttler) Failure(ip, tokenStr string) {
+	maxFailures := 0
+	for _, s := range t.subjects(ip, tokenStr) {
+		n, err := t.redisStore.IncrWithTTL("authfail:"+s.key, t.cfg.Window)
+		if err != nil {
+			ll.Error("Error recording failed authentication", l.Error(err))
+			continue
+		}
+		if n > maxFailures {
+			maxFailures = n
+		}
+		if !s.bannable {
+			// Reported once per window
+			if n == t.cfg.MaxFailures {
+				ll.Warn("Failed authentications with a token prefix", l.String("subject", s.key), l.Int("failures", n))
+				t.emit(EventSuspiciousPrefix, ip, tokenStr, n)
+			}
+			continue
+		}
+		if n < t.cfg.MaxFailures {
+			continue
+		}
+
+		if err := t.redisStore.SetStringWithTTL("authban:"+s.key, "1", t.cfg.BanTTL); err != nil {
+			ll.Error("Error banning client", l.Error(err))
+			continue
+		}
+		if err := t.redisStore.Del("authfail:" + s.key); err != nil {
+			ll.Error("Error resetting failed authentications", l.Error(err))
+		}
+		ll.Warn("Client banned after failed authentications", l.String("subject", s.key), l.Int("failures", n))
+		t.emit(EventBanned, ip, tokenStr, n)
+	}
+	t.emit(EventAuthFailed, ip, tokenStr, maxFailures)
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottler(t *testing.T) {
+	var events []SecurityEvent
+	th := NewThrottler(rStore, ThrottleConfig{
+		MaxFailures: 4,
+		DelayAfter:  1,
+		BaseDelay:   time.Millisecond,
+		OnEvent: func(e SecurityEvent) {
+			events = append(events, e)
+		},
+	})
+	ip := uuid.NewV4().String()
+	token := RandomToken(DefaultTokenLength)
+
+	delay, err := th.Allow(ip, token)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay)
+
+	th.Failure(ip, token)
+	th.Failure(ip, token)
+	th.Failure(ip, token)
+	delay, err = th.Allow(ip, token)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Millisecond, delay)
+
+	th.Failure(ip, token)
+	_, err = th.Allow(ip, token)
+	assert.Equal(t, ErrBanned, err)
+
+	// Other clients sending tokens with the same prefix are not banned
+	_, err = th.Allow(uuid.NewV4().String(), token[:8]+RandomToken(DefaultTokenLength))
+	assert.NoError(t, err)
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, EventBlocked, events[len(events)-1].Type)
+	assert.Equal(t, Fingerprint(token), events[len(events)-1].Token)
+}
+
+func TestThrottlerTokenPrefix(t *testing.T) {
+	var events []SecurityEvent
+	th := NewThrottler(rStore, ThrottleConfig{
+		MaxFailures:       3,
+		DelayAfter:        1,
+		BaseDelay:         time.Millisecond,
+		TokenPrefixLength: 8,
+		OnEvent: func(e SecurityEvent) {
+			events = append(events, e)
+		},
+	})
+	prefix := RandomToken(DefaultTokenLength)[:8]
+
+	// Failures from many IPs with the same token prefix
+	for i := 0; i < 5; i++ {
+		th.Failure(uuid.NewV4().String(), prefix+RandomToken(DefaultTokenLength))
+	}
+
+	// Are delayed for other IPs, but never banned
+	delay, err := th.Allow(uuid.NewV4().String(), prefix+RandomToken(DefaultTokenLength))
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Millisecond, delay)
+
+	delay, err = th.Allow(uuid.NewV4().String(), RandomToken(DefaultTokenLength))
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay)
+
+	var suspicious int
+	for _, e := range events {
+		assert.NotEqual(t, EventBanned, e.Type)
+		if e.Type == EventSuspiciousPrefix {
+			suspicious++
+		}
+	}
+	assert.Equal(t, 1, suspicious)
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"io"
-	"strings"
+	"time"
 
 	"github.com/go-xtek/vuvo-go/l"
 	"github.com/go-xtek/vuvo-go/redis"
@@ -28,17 +28,63 @@ var ErrInvalid = errors.New("Invalid token")
 
 var ll = l.New()
 
-// Validator interface
+// Validator interface. Validate returns ErrInvalid for invalid tokens, or
+// errors for which IsTemporary is true when the token could not be
+// validated, e.g. the repository is unreachable. Other errors, e.g. parse
+// errors of malformed tokens, are treated as ErrInvalid.
 type Validator interface {
 	Validate(tokenStr string) (Token, error)
 }
 
+// temporaryError marks an error as transient, see Temporary
+type temporaryError struct {
+	err error
+}
+
+func (e temporaryError) Error() string {
+	return e.err.Error()
+}
+
+func (e temporaryError) Temporary() bool {
+	return true
+}
+
+// Temporary marks err as transient, so the token may be valid on next request
+func Temporary(err error) error {
+	if err == nil || IsTemporary(err) {
+		return err
+	}
+	return temporaryError{err}
+}
+
+// IsTemporary reports whether err is transient, i.e. it implements
+// Temporary() bool returning true, as errors returned by Temporary and
+// net.Error do
+func IsTemporary(err error) bool {
+	t, ok := err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
 // Token represents a token used in request/response
 type Token struct {
 	TokenStr  string
 	SubjectID string
 	UserID    string
 	Value     string
+
+	// CreatedAt and LastSeenAt are only populated by generators
+	// with sliding expiration enabled
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+
+	// StepUp reports whether the session passed step-up authentication,
+	// e.g. TOTP. It is only populated by generators with WithStepUp.
+	StepUp bool
+
+	// ActorID is the user acting on behalf of UserID with a delegated
+	// token, restricted to Scopes.
+	ActorID string
+	Scopes  []string
 }
 
 // Store interface contains methods
@@ -47,42 +93,93 @@ type Store interface {
 	Generate(userID string, ttl int) (Token, error)
 	GenerateWithValue(userID string, value string, ttl int) (Token, error)
 	Revoke(tokenStr string) error
-	SetInfo(tokenStr string, value string) error
-	GetInfo(tokenStr string) (string, error)
+	SetInfo(tokenStr string, info map[string]string) error
+	GetInfo(tokenStr string) (map[string]string, error)
+}
+
+// Consumer is implemented by generators of single use tokens, e.g.
+// authorization codes. Consume validates and revokes a token atomically,
+// it returns ErrInvalid if the token is already consumed.
+type Consumer interface {
+	Consume(tokenStr string) (Token, error)
 }
 
-// Generator interface
+// ErrConsumeUnsupported returns an error indicate that
+// the token repository does not implement TokenConsumer
+var ErrConsumeUnsupported = errors.New("Token repository does not support consuming tokens")
+
+// Generator interface. Generators returned by this package also implement
+// Inspector, Consumer, StepUpMarker and Delegator.
 type Generator interface {
 	Validator
 	Store
 }
 
 type generator struct {
-	name       string
-	redisStore redis.Store
+	name string
+	repo TokenRepository
+
+	sliding    *SlidingExpiration
+	hashSecret []byte
+	legacyKeys bool
+	stepUp     bool
+	delegation bool
 }
 
-// NewGenerator returns new token generator
-func NewGenerator(name string, r redis.Store) Generator {
+// GeneratorOption allows optional config for generator
+type GeneratorOption func(g *generator)
+
+// NewGenerator returns new token generator storing tokens in redis
+func NewGenerator(name string, r redis.Store, opts ...GeneratorOption) Generator {
+	return NewGeneratorWithRepository(name, NewRedisTokenRepository(r), opts...)
+}
+
+// atomicStore returns r as redis.AtomicStore, which is required by stores
+// of this package. Stores returned by redis.New implement it.
+func atomicStore(r redis.Store) redis.AtomicStore {
+	s, ok := r.(redis.AtomicStore)
+	if !ok {
+		panic("auth: redis store must implement redis.AtomicStore")
+	}
+	return s
+}
+
+// NewGeneratorWithRepository returns new token generator storing tokens
+// in given repository
+func NewGeneratorWithRepository(name string, repo TokenRepository, opts ...GeneratorOption) Generator {
 	if name == "" {
 		name = DefaultTokenPrefix
 	}
-	return &generator{
-		name:       name,
-		redisStore: r,
+	g := &generator{
+		name: name,
+		repo: repo,
+	}
+	for _, fn := range opts {
+		fn(g)
 	}
+	return g
 }
 
-// toKey returns string that can be used as redis key
+// toKey returns string that can be used as repository key
 // from given token
 func (g *generator) toKey(t Token) string {
-	return t.SubjectID + ":" + t.TokenStr
+	if g.hashSecret == nil {
+		return g.toLegacyKey(t)
+	}
+	return t.SubjectID + ":" + g.hashToken(t.TokenStr)
 }
 
-// toValue returns string that can be used as redis value
-// from given token
-func (g *generator) toValue(t Token) string {
-	return t.UserID + ":" + t.Value
+// toRecord returns repository record of given token
+func (g *generator) toRecord(t Token) TokenRecord {
+	return TokenRecord{
+		Key:        g.toKey(t),
+		UserID:     t.UserID,
+		Value:      t.Value,
+		ActorID:    t.ActorID,
+		Scopes:     t.Scopes,
+		CreatedAt:  t.CreatedAt,
+		LastSeenAt: t.LastSeenAt,
+	}
 }
 
 // Generate creates token for given userID and TTL.
@@ -107,23 +204,26 @@ func (g *generator) GenerateWithValue(userID, value string, ttl int) (Token, err
 }
 
 func (g *generator) generate(t Token, ttl int) (Token, error) {
+	if g.sliding != nil {
+		ttl = g.lifetimeTTL(time.Now(), ttl)
+
+		now := time.Now()
+		t.CreatedAt, t.LastSeenAt = now, now
+	}
+
 	retry := 0
 	for {
 		token := RandomToken(DefaultTokenLength)
 		t.TokenStr = token
 
-		key := g.toKey(t)
-		if g.redisStore.IsExist(key) {
+		err := g.repo.Create(g.toRecord(t), ttl)
+		if err == ErrTokenExists {
 			retry++
 			if retry >= 3 {
 				panic("Unable to generate token, retried 3 times!")
 			}
 			continue
 		}
-
-		value := g.toValue(t)
-		err := g.redisStore.SetStringWithTTL(key, value, ttl)
-
 		return t, err
 	}
 }
@@ -135,58 +235,99 @@ func (g *generator) Validate(token string) (Token, error) {
 	}
 
 	// Check if the token exist in database
-	key := g.toKey(t)
-	storedValue, err := g.redisStore.GetString(key)
-	if err != nil || storedValue == "" {
+	rec, err := g.repo.Get(g.toKey(t))
+	if err == ErrInvalid && g.hashSecret != nil && g.legacyKeys {
+		rec, err = g.validateLegacy(t)
+	}
+	if err == ErrInvalid {
 		return t, ErrInvalid
 	}
+	if err != nil {
+		ll.Error("Error validating token", l.String("token", Fingerprint(token)), l.Error(err))
+		return t, Temporary(err)
+	}
 
-	s := strings.Split(storedValue, ":")
-	t.UserID = s[0]
-	t.Value = s[1]
+	t = g.fromRecord(t, rec)
 
+	// Delegated tokens are never extended
+	if g.sliding != nil && t.ActorID == "" {
+		return g.touch(t)
+	}
 	return t, nil
 }
 
-// Revoke deletes token from redis store.
-func (g *generator) Revoke(tokenStr string) error {
+func (g *generator) fromRecord(t Token, rec TokenRecord) Token {
+	t.UserID = rec.UserID
+	t.Value = rec.Value
+	t.ActorID = rec.ActorID
+	t.Scopes = rec.Scopes
+	t.CreatedAt = rec.CreatedAt
+	t.LastSeenAt = rec.LastSeenAt
+	if g.stepUp {
+		t.StepUp = rec.StepUp
+	}
+	return t
+}
+
+// Consume implements Consumer, the repository must implement TokenConsumer
+func (g *generator) Consume(tokenStr string) (Token, error) {
 	t := Token{
 		TokenStr:  tokenStr,
 		SubjectID: g.name,
 	}
-	key := g.toKey(t)
-	err := g.redisStore.Del(key)
-	if err != nil {
-		ll.Error("Error revoking token", l.Error(err))
+	consumer, ok := g.repo.(TokenConsumer)
+	if !ok {
+		return t, ErrConsumeUnsupported
 	}
-	return err
-}
 
-// SetInfo set information for given token
-func (g *generator) SetInfo(tokenStr string, value string) error {
-	ttl, err := g.redisStore.GetTTL(tokenStr)
+	rec, err := consumer.Consume(g.toKey(t))
+	if err == ErrInvalid && g.hashSecret != nil && g.legacyKeys {
+		rec, err = consumer.Consume(g.toLegacyKey(t))
+	}
+	if err == ErrInvalid {
+		return t, ErrInvalid
+	}
 	if err != nil {
-		return err
+		ll.Error("Error consuming token", l.String("token", Fingerprint(tokenStr)), l.Error(err))
+		return t, err
 	}
+	return g.fromRecord(t, rec), nil
+}
 
-	t := Token{TokenStr: tokenStr}
-	key := g.toKey(t)
-	err = g.redisStore.SetStringWithTTL(key, value, ttl)
+// Revoke deletes token from repository.
+func (g *generator) Revoke(tokenStr string) error {
+	t := Token{
+		TokenStr:  tokenStr,
+		SubjectID: g.name,
+	}
+	keys := []string{g.toKey(t)}
+	if g.hashSecret != nil && g.legacyKeys {
+		keys = append(keys, g.toLegacyKey(t))
+	}
+	err := g.repo.Delete(keys...)
 	if err != nil {
-		return err
+		ll.Error("Error revoking token", l.Error(err))
 	}
-
-	return nil
+	return err
 }
 
-// GetInfo return infomation for given token
-func (g *generator) GetInfo(tokenStr string) (string, error) {
-	storedValue, err := g.redisStore.GetString(tokenStr)
-	if err != nil {
-		return "", err
+// SetInfo updates metadata attached to given token, keeping its remaining
+// TTL. Only given fields are changed, fields with empty value are removed.
+func (g *generator) SetInfo(tokenStr string, info map[string]string) error {
+	t := Token{
+		TokenStr:  tokenStr,
+		SubjectID: g.name,
 	}
+	return g.repo.SetInfo(g.toKey(t), info)
+}
 
-	return storedValue, nil
+// GetInfo returns metadata attached to given token
+func (g *generator) GetInfo(tokenStr string) (map[string]string, error) {
+	t := Token{
+		TokenStr:  tokenStr,
+		SubjectID: g.name,
+	}
+	return g.repo.GetInfo(g.toKey(t))
 }
 
 // RandomToken generate new base64 string from random byte array with given length
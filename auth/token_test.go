@@ -15,8 +15,9 @@ import (
 )
 
 var (
-	gFoo Generator
-	gBar Generator
+	rStore redis.Store
+	gFoo   Generator
+	gBar   Generator
 )
 
 func TestMain(M *testing.M) {
@@ -42,7 +43,7 @@ func TestMain(M *testing.M) {
 			return err
 		},
 	}
-	rStore := redis.New(redisPool)
+	rStore = redis.New(redisPool)
 	gFoo = NewGenerator("foo", rStore)
 	gBar = NewGenerator("bar", rStore)
 
@@ -119,3 +120,187 @@ func TestTokenWithTTL(T *testing.T) {
 		T.Fatal("Key does not expired")
 	}
 }
+
+func TestSlidingExpiration(T *testing.T) {
+	id := uuid.NewV4().String()
+
+	T.Run("Extend on validate", func(t *testing.T) {
+		g := NewGenerator("sliding", rStore, WithSlidingExpiration(SlidingExpiration{TTL: 10}))
+		tok, err := g.Generate(id, 1)
+		require.NoError(t, err)
+		defer g.Revoke(tok.TokenStr)
+
+		got, err := g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		assert.False(t, got.LastSeenAt.IsZero())
+
+		time.Sleep(1005 * time.Millisecond)
+
+		_, err = g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+	})
+
+	T.Run("Bounded by max lifetime", func(t *testing.T) {
+		g := NewGenerator("sliding", rStore, WithSlidingExpiration(SlidingExpiration{TTL: 10, MaxLifetime: 1}))
+		tok, err := g.Generate(id, 10)
+		require.NoError(t, err)
+
+		_, err = g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+
+		time.Sleep(1005 * time.Millisecond)
+
+		_, err = g.Validate(tok.TokenStr)
+		assert.EqualError(t, err, "Invalid token")
+	})
+
+	T.Run("Invalid config", func(t *testing.T) {
+		assert.Panics(t, func() { WithSlidingExpiration(SlidingExpiration{}) })
+		assert.Panics(t, func() { WithSlidingExpiration(SlidingExpiration{TTL: 10, Throttle: 10}) })
+		assert.NotPanics(t, func() { WithSlidingExpiration(SlidingExpiration{TTL: 10, Throttle: 9}) })
+	})
+}
+
+func TestHashedKeys(T *testing.T) {
+	secret := []byte("secret")
+	id := uuid.NewV4().String()
+
+	T.Run("Raw token is not stored", func(t *testing.T) {
+		g := NewGenerator("hashed", rStore, WithHashedKeys(secret))
+		tok, err := g.Generate(id, DefaultTTL)
+		require.NoError(t, err)
+		defer g.Revoke(tok.TokenStr)
+
+		assert.False(t, rStore.IsExist("hashed:"+tok.TokenStr))
+
+		got, err := g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.UserID)
+	})
+
+	T.Run("Migrate legacy token", func(t *testing.T) {
+		legacy := NewGenerator("hashed", rStore)
+		tok, err := legacy.Generate(id, DefaultTTL)
+		require.NoError(t, err)
+
+		g := NewGenerator("hashed", rStore, WithHashedKeys(secret))
+		_, err = g.Validate(tok.TokenStr)
+		assert.EqualError(t, err, "Invalid token")
+
+		g = NewGenerator("hashed", rStore, WithHashedKeys(secret), WithLegacyKeys())
+		got, err := g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.UserID)
+		assert.False(t, rStore.IsExist("hashed:"+tok.TokenStr))
+
+		require.NoError(t, g.Revoke(tok.TokenStr))
+		_, err = g.Validate(tok.TokenStr)
+		assert.EqualError(t, err, "Invalid token")
+	})
+
+	T.Run("Migrate legacy token with attached data", func(t *testing.T) {
+		legacy := NewGenerator("hashed", rStore, WithStepUp())
+		tok, err := legacy.Generate(id, 100)
+		require.NoError(t, err)
+		require.NoError(t, legacy.SetInfo(tok.TokenStr, map[string]string{"device": "ios"}))
+		require.NoError(t, legacy.(StepUpMarker).MarkStepUp(tok.TokenStr, 60))
+
+		g := NewGenerator("hashed", rStore, WithHashedKeys(secret), WithLegacyKeys(), WithStepUp())
+		got, err := g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		defer g.Revoke(tok.TokenStr)
+		assert.True(t, got.StepUp)
+		assert.False(t, rStore.IsExist("hashed:"+tok.TokenStr))
+
+		info, err := g.GetInfo(tok.TokenStr)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"device": "ios"}, info)
+
+		got, err = g.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		assert.True(t, got.StepUp)
+		ttl, err := rStore.GetTTL("hashed:" + g.(*generator).hashToken(tok.TokenStr))
+		require.NoError(t, err)
+		assert.True(t, ttl > 0 && ttl <= 100, "ttl %v", ttl)
+	})
+}
+
+func TestSetGetInfo(T *testing.T) {
+	id := uuid.NewV4().String()
+	tok, err := gFoo.Generate(id, 100)
+	require.NoError(T, err)
+	defer gFoo.Revoke(tok.TokenStr)
+
+	T.Run("Partial update", func(t *testing.T) {
+		err := gFoo.SetInfo(tok.TokenStr, map[string]string{"device": "ios", "ip": "10.0.0.1"})
+		require.NoError(t, err)
+
+		err = gFoo.SetInfo(tok.TokenStr, map[string]string{"ip": "", "locale": "vi"})
+		require.NoError(t, err)
+
+		info, err := gFoo.GetInfo(tok.TokenStr)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"device": "ios", "locale": "vi"}, info)
+	})
+
+	T.Run("Keep token TTL", func(t *testing.T) {
+		ttl, err := rStore.GetTTL("foo:" + tok.TokenStr + ":info")
+		require.NoError(t, err)
+		assert.True(t, ttl > 0 && ttl <= 100)
+
+		// The token itself is untouched
+		got, err := gFoo.Validate(tok.TokenStr)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.UserID)
+	})
+
+	T.Run("Invalid token", func(t *testing.T) {
+		err := gFoo.SetInfo("invalid", map[string]string{"foo": "bar"})
+		assert.Equal(t, ErrInvalid, err)
+
+		_, err = gFoo.GetInfo("invalid")
+		assert.Equal(t, ErrInvalid, err)
+	})
+}
+
+func TestDelegatedToken(T *testing.T) {
+	g := NewGenerator("delegated", rStore, WithDelegation())
+	actorID := uuid.NewV4().String()
+	userID := uuid.NewV4().String()
+
+	tok, err := g.(Delegator).GenerateDelegated(actorID, userID, []string{"orders:read"}, DefaultTTL)
+	require.NoError(T, err)
+	defer g.Revoke(tok.TokenStr)
+
+	ttl, err := rStore.GetTTL("delegated:" + tok.TokenStr)
+	require.NoError(T, err)
+	assert.True(T, ttl <= DelegationMaxTTL)
+
+	got, err := g.Validate(tok.TokenStr)
+	require.NoError(T, err)
+	assert.Equal(T, userID, got.UserID)
+	assert.Equal(T, actorID, got.ActorID)
+	assert.True(T, got.IsDelegated())
+	assert.True(T, got.HasScope("orders:read"))
+	assert.False(T, got.HasScope("orders:write"))
+
+	_, err = g.(Delegator).GenerateDelegated(userID, userID, []string{"orders:read"}, 0)
+	assert.Error(T, err)
+}
+
+func TestDelegatedTokenWithoutOption(T *testing.T) {
+	g := NewGenerator("delegated", rStore, WithDelegation())
+	v := NewGenerator("delegated", rStore)
+	actorID := uuid.NewV4().String()
+	userID := uuid.NewV4().String()
+
+	tok, err := g.(Delegator).GenerateDelegated(actorID, userID, []string{"orders:read"}, DefaultTTL)
+	require.NoError(T, err)
+	defer g.Revoke(tok.TokenStr)
+
+	// Restrictions hold on validators without WithDelegation
+	got, err := v.Validate(tok.TokenStr)
+	require.NoError(T, err)
+	assert.Equal(T, actorID, got.ActorID)
+	assert.False(T, got.HasScope("orders:write"))
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/redis"
+)
+
+// Default values of TOTPConfig
+const (
+	DefaultTOTPDigits        = 6
+	DefaultTOTPPeriod        = 30 // In seconds
+	DefaultTOTPSkew          = 1
+	DefaultRecoveryCodeCount = 10
+	DefaultTOTPMaxFailures   = 5
+	DefaultTOTPLockTTL       = 15 * 60 // In seconds
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPConfig configures time-based one-time passwords (RFC 6238) with
+// HMAC-SHA1, which is supported by all authenticator apps
+type TOTPConfig struct {
+	Issuer string
+	Digits int
+	Period int
+
+	// Skew is the number of periods before and after current time
+	// which are accepted to tolerate clock drift
+	Skew int
+
+	RecoveryCodeCount int
+
+	// Verify is locked for LockTTL seconds after MaxFailures consecutive
+	// failed codes of a user
+	MaxFailures int
+	LockTTL     int
+}
+
+func (c TOTPConfig) withDefaults() TOTPConfig {
+	if c.Digits == 0 {
+		c.Digits = DefaultTOTPDigits
+	}
+	if c.Digits < 6 || c.Digits > 8 {
+		panic("auth: TOTP digits must be between 6 and 8")
+	}
+	if c.Period == 0 {
+		c.Period = DefaultTOTPPeriod
+	}
+	if c.Skew == 0 {
+		c.Skew = DefaultTOTPSkew
+	}
+	if c.RecoveryCodeCount == 0 {
+		c.RecoveryCodeCount = DefaultRecoveryCodeCount
+	}
+	if c.MaxFailures == 0 {
+		c.MaxFailures = DefaultTOTPMaxFailures
+	}
+	if c.LockTTL == 0 {
+		c.LockTTL = DefaultTOTPLockTTL
+	}
+	return c
+}
+
+// GenerateTOTPSecret returns new base32 encoded secret
+func GenerateTOTPSecret() string {
+	secret := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		panic(err)
+	}
+	return totpEncoding.EncodeToString(secret)
+}
+
+// URI returns otpauth:// provisioning URI, which is usually shown as
+// a QR code to be scanned by authenticator apps
+func (c TOTPConfig) URI(account, secret string) string {
+	c = c.withDefaults()
+
+	label := account
+	if c.Issuer != "" {
+		label = c.Issuer + ":" + account
+	}
+	v := url.Values{}
+	v.Set("secret", secret)
+	if c.Issuer != "" {
+		v.Set("issuer", c.Issuer)
+	}
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", strconv.Itoa(c.Digits))
+	v.Set("period", strconv.Itoa(c.Period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the password of given secret at given time
+func (c TOTPConfig) Code(secret string, t time.Time) (string, error) {
+	c = c.withDefaults()
+	return c.hotp(secret, uint64(t.Unix())/uint64(c.Period))
+}
+
+func (c TOTPConfig) hotp(secret string, counter uint64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < c.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", c.Digits, value%mod), nil
+}
+
+// TOTPStore verifies TOTP codes and recovery codes of users
+type TOTPStore interface {
+	// Verify checks given code against the user's secret. Each code can
+	// only be used once. It returns ErrBanned while the user is locked
+	// after too many failures.
+	Verify(userID, secret, code string) error
+
+	// GenerateRecoveryCodes replaces recovery codes of given user.
+	// The codes are only available at this time.
+	GenerateRecoveryCodes(userID string) ([]string, error)
+
+	// UseRecoveryCode consumes given recovery code
+	UseRecoveryCode(userID, code string) error
+}
+
+type totpStore struct {
+	cfg        TOTPConfig
+	redisStore redis.AtomicStore
+}
+
+// NewTOTPStore returns new TOTP store
+func NewTOTPStore(r redis.Store, cfg TOTPConfig) TOTPStore {
+	return &totpStore{
+		cfg:        cfg.withDefaults(),
+		redisStore: atomicStore(r),
+	}
+}
+
+func (s *totpStore) toUsedKey(userID string, counter uint64) string {
+	return "totp:" + userID + ":used:" + strconv.FormatUint(counter, 10)
+}
+
+func (s *totpStore) toFailureKey(userID string) string {
+	return "totp:" + userID + ":failures"
+}
+
+func (s *totpStore) toLockKey(userID string) string {
+	return "totp:" + userID + ":locked"
+}
+
+func (s *totpStore) toRecoveryKey(userID, hash string) string {
+	return "totp:" + userID + ":recovery:" + hash
+}
+
+func (s *totpStore) Verify(userID, secret, code string) error {
+	if s.redisStore.IsExist(s.toLockKey(userID)) {
+		return ErrBanned
+	}
+
+	err := s.verify(userID, secret, code)
+	if err == ErrInvalid {
+		s.failure(userID)
+	}
+	return err
+}
+
+func (s *totpStore) verify(userID, secret, code string) error {
+	if len(code) != s.cfg.Digits {
+		return ErrInvalid
+	}
+
+	now := uint64(time.Now().Unix()) / uint64(s.cfg.Period)
+	for i := -s.cfg.Skew; i <= s.cfg.Skew; i++ {
+		counter := now + uint64(i)
+		expected, err := s.cfg.hotp(secret, counter)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(expected), []byte(code)) {
+			continue
+		}
+
+		// Reject replay of the same code within the accepted window
+		ttl := s.cfg.Period * (2*s.cfg.Skew + 1)
+		n, err := s.redisStore.IncrWithTTL(s.toUsedKey(userID, counter), ttl)
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			ll.Warn("TOTP code replayed", l.String("user", userID))
+			return ErrInvalid
+		}
+		if err := s.redisStore.Del(s.toFailureKey(userID)); err != nil {
+			ll.Error("Error resetting TOTP failures", l.Error(err))
+		}
+		return nil
+	}
+	return ErrInvalid
+}
+
+// failure records a failed code of given user and locks the user after
+// MaxFailures failures
+func (s *totpStore) failure(userID string) {
+	n, err := s.redisStore.IncrWithTTL(s.toFailureKey(userID), s.cfg.LockTTL)
+	if err != nil {
+		ll.Error("Error recording TOTP failure", l.Error(err))
+		return
+	}
+	if n < s.cfg.MaxFailures {
+		return
+	}
+
+	if err := s.redisStore.SetStringWithTTL(s.toLockKey(userID), "1", s.cfg.LockTTL); err != nil {
+		ll.Error("Error locking TOTP", l.Error(err))
+		return
+	}
+	if err := s.redisStore.Del(s.toFailureKey(userID)); err != nil {
+		ll.Error("Error resetting TOTP failures", l.Error(err))
+	}
+	ll.Warn("TOTP locked after failed codes", l.String("user", userID), l.Int("failures", n))
+}
+
+func (s *totpStore) GenerateRecoveryCodes(userID string) ([]string, error) {
+	existing, err := s.redisStore.GetStrings(s.toRecoveryKey(userID, "*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		if err := s.redisStore.Del(existing...); err != nil {
+			return nil, err
+		}
+	}
+
+	codes := make([]string, s.cfg.RecoveryCodeCount)
+	for i := range codes {
+		code := RandomCode(10, CharsetAlphanumeric)
+		codes[i] = code[:5] + "-" + code[5:]
+		if err := s.redisStore.SetString(s.toRecoveryKey(userID, hashOneTimeCode(codes[i])), "1"); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func (s *totpStore) UseRecoveryCode(userID, code string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	v, err := s.redisStore.GetDel(s.toRecoveryKey(userID, hashOneTimeCode(code)))
+	if err != nil {
+		return err
+	}
+	if v == "" {
+		return ErrInvalid
+	}
+	return nil
+}
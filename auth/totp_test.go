@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors from RFC 6238, truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	cfg := TOTPConfig{}
+
+	for ts, expected := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := cfg.Code(secret, time.Unix(ts, 0))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	code, err := TOTPConfig{Digits: 8}.Code(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "94287082", code)
+
+	assert.Panics(t, func() { TOTPConfig{Digits: 9}.Code(secret, time.Now()) })
+	assert.Panics(t, func() { NewTOTPStore(rStore, TOTPConfig{Digits: 4}) })
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPConfig{Issuer: "Vuvo"}.URI("alice@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Vuvo:alice@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Vuvo")
+}
+
+func TestTOTPStore(T *testing.T) {
+	s := NewTOTPStore(rStore, TOTPConfig{RecoveryCodeCount: 2})
+	userID := uuid.NewV4().String()
+	secret := GenerateTOTPSecret()
+
+	T.Run("Verify once", func(t *testing.T) {
+		code, err := TOTPConfig{}.Code(secret, time.Now())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Verify(userID, secret, code))
+		assert.Equal(t, ErrInvalid, s.Verify(userID, secret, code))
+	})
+
+	T.Run("Lock after failures", func(t *testing.T) {
+		s := NewTOTPStore(rStore, TOTPConfig{MaxFailures: 3})
+		userID := uuid.NewV4().String()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, ErrInvalid, s.Verify(userID, secret, "000000"))
+		}
+
+		code, err := TOTPConfig{}.Code(secret, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, ErrBanned, s.Verify(userID, secret, code))
+	})
+
+	T.Run("Recovery codes", func(t *testing.T) {
+		codes, err := s.GenerateRecoveryCodes(userID)
+		require.NoError(t, err)
+		require.Len(t, codes, 2)
+
+		require.NoError(t, s.UseRecoveryCode(userID, strings.ToLower(codes[0])))
+		assert.Equal(t, ErrInvalid, s.UseRecoveryCode(userID, codes[0]))
+
+		_, err = s.GenerateRecoveryCodes(userID)
+		require.NoError(t, err)
+		assert.Equal(t, ErrInvalid, s.UseRecoveryCode(userID, codes[1]))
+	})
+}
+
+func TestStepUp(t *testing.T) {
+	g := NewGenerator("stepup", rStore, WithStepUp())
+	tok, err := g.Generate(uuid.NewV4().String(), DefaultTTL)
+	require.NoError(t, err)
+	defer g.Revoke(tok.TokenStr)
+
+	got, err := g.Validate(tok.TokenStr)
+	require.NoError(t, err)
+	assert.False(t, got.StepUp)
+
+	require.NoError(t, g.(StepUpMarker).MarkStepUp(tok.TokenStr, 60))
+
+	got, err = g.Validate(tok.TokenStr)
+	require.NoError(t, err)
+	assert.True(t, got.StepUp)
+}
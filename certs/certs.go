@@ -0,0 +1,203 @@
+// Package certs loads TLS certificates from files and reloads them when
+// the files are rotated, e.g. by cert-manager or a certbot hook
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// Default values of Config
+const (
+	DefaultMinVersion     = tls.VersionTLS12
+	DefaultReloadInterval = time.Minute
+)
+
+var ll = l.New()
+
+// ErrNoCertificates returns an error indicate that
+// the client CA file contains no PEM certificate
+var ErrNoCertificates = errors.New("No certificate found")
+
+// Config ...
+type Config struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile enables mutual TLS, clients must present a certificate
+	// signed by one of its CAs. ClientAuth defaults to
+	// tls.RequireAndVerifyClientCert then.
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+
+	// MinVersion defaults to DefaultMinVersion
+	MinVersion uint16
+
+	// ReloadInterval is how often files are checked for changes
+	ReloadInterval time.Duration
+}
+
+// Reloader serves the certificates last loaded from files
+type Reloader struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	modTimes map[string]time.Time
+}
+
+// NewReloader loads the files, it returns an error if they are not valid
+func NewReloader(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("CertFile and KeyFile required")
+	}
+	if cfg.ClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = DefaultMinVersion
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultReloadInterval
+	}
+
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a config serving the current certificates and client
+// CAs from GetConfigForClient. It can be cloned, e.g. by grpc
+// credentials.NewTLS.
+func (r *Reloader) TLSConfig() *tls.Config {
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+
+	return &tls.Config{
+		ClientCAs:  cfg.ClientCAs,
+		ClientAuth: cfg.ClientAuth,
+		MinVersion: cfg.MinVersion,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// Certificate returns the current certificate
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &r.config.Certificates[0]
+}
+
+// Reload loads the files. The current certificates are kept on error.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.cfg.ClientAuth,
+		MinVersion:   r.cfg.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.cfg.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(data) {
+			return ErrNoCertificates
+		}
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Run checks the files every ReloadInterval until ctx is done and reloads
+// them when they are modified
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if !r.modified() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			// Files may be written one after another, retry on next tick
+			ll.Error("Unable to reload certificates", l.String("cert", r.cfg.CertFile), l.Error(err))
+			continue
+		}
+		ll.Info("Reloaded certificates", l.String("cert", r.cfg.CertFile))
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *Reloader) modified() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		ll.Warn("Unable to check certificates", l.Error(err))
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for file, t := range modTimes {
+		if !t.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
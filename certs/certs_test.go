@@ -0,0 +1,112 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for commonName to dir
+func writeCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestNewReloader(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(t, dir, "v1")
+
+	r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", commonName(t, r.Certificate()))
+
+	cfg, err := r.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.NotNil(t, cfg.ClientCAs)
+
+	_, err = NewReloader(Config{CertFile: certFile, KeyFile: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+	_, err = NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile})
+	assert.Equal(t, ErrNoCertificates, err)
+}
+
+func TestReloaderRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(t, dir, "v1")
+
+	r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// An invalid key keeps the current certificate
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("invalid"), 0600))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "v1", commonName(t, r.Certificate()))
+
+	writeCert(t, dir, "v2")
+	future = future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	for i := 0; i < 100 && commonName(t, r.Certificate()) != "v2"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "v2", commonName(t, r.Certificate()))
+
+	cert, err := r.TLSConfig().GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", commonName(t, cert))
+}
@@ -0,0 +1,372 @@
+// Package config loads a typed config struct from files, environment
+// variables and flags.
+//
+// Fields are named by their yaml tag, or their lowercased name. A field
+// server.http_port of a struct
+//
+//	type Config struct {
+//		Server config.Server `yaml:"server"`
+//	}
+//
+// is read from the key http_port of the section server in files, from
+// PREFIX_SERVER_HTTP_PORT in the environment and from the flag
+// -server.http_port. Later sources override earlier ones: values already
+// in the struct are defaults, then files in given order, then environment,
+// then flags.
+//
+// Field tags
+//
+//	env:"NAME"        read from NAME instead of the derived variable
+//	usage:"..."       description of the flag
+//	required:"true"   the field must not be empty
+//	secret:"true"     the value is redacted, see Redacted
+//
+// Structs implementing Validator are validated after loading.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Redaction replaces values of secret fields
+const Redaction = "REDACTED"
+
+var ll = l.New()
+
+// Validator is implemented by config structs with rules beyond required
+// fields, e.g. fields which must be set together
+type Validator interface {
+	Validate() error
+}
+
+// Error lists the problems found while loading
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "Invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+type options struct {
+	files     []string
+	envPrefix string
+	flagSet   *flag.FlagSet
+	args      []string
+}
+
+// Option allows optional config for Load
+type Option func(*options)
+
+// WithFiles reads YAML files, JSON files are read as YAML. Missing files
+// are errors.
+func WithFiles(files ...string) Option {
+	return func(o *options) {
+		o.files = append(o.files, files...)
+	}
+}
+
+// WithEnvPrefix prefixes names of environment variables, e.g. APP
+// reads APP_SERVER_PORT
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithFlags defines a flag for each field on fs and parses args, e.g.
+// flag.CommandLine and os.Args[1:]. Flags are defined once, so Load can be
+// called again with the same fs.
+func WithFlags(fs *flag.FlagSet, args []string) Option {
+	return func(o *options) {
+		o.flagSet = fs
+		o.args = args
+	}
+}
+
+// Load fills cfg, which must be a pointer to a struct, from the sources in
+// order of precedence and validates it. The result is logged with secret
+// values redacted.
+func Load(cfg interface{}, opts ...Option) error {
+	var o options
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Config must be a pointer to struct, got %T", cfg)
+	}
+	fields := collect(v.Elem(), nil)
+
+	for _, file := range o.files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+	}
+
+	var problems []string
+	for _, f := range fields {
+		name := f.env(o.envPrefix)
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := f.set(s); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", name, err))
+		}
+	}
+
+	if o.flagSet != nil {
+		for _, f := range fields {
+			// Flags defined by a previous Load set the fields of cfg now
+			if defined := o.flagSet.Lookup(f.name()); defined != nil {
+				fv, ok := defined.Value.(*flagValue)
+				if !ok {
+					return fmt.Errorf("Flag %v is already defined", f.name())
+				}
+				fv.f = f
+				continue
+			}
+			o.flagSet.Var(&flagValue{f: f}, f.name(), f.usage(o.envPrefix))
+		}
+		if err := o.flagSet.Parse(o.args); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		if f.required() && isZero(f.v) {
+			problems = append(problems, f.name()+" is required")
+		}
+	}
+	validate(v, &problems)
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+
+	ll.Info("Loaded config", l.Object("config", Redacted(cfg)))
+	return nil
+}
+
+// validate calls Validate of v and its nested structs
+func validate(v reflect.Value, problems *[]string) {
+	if validator, ok := v.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			*problems = append(*problems, err.Error())
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" || !isSection(t.Field(i).Type) {
+			continue
+		}
+		validate(v.Field(i).Addr(), problems)
+	}
+}
+
+// Redacted returns the fields of cfg by name, values of secret fields
+// which are set are replaced by Redaction
+func Redacted(cfg interface{}) map[string]interface{} {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	result := make(map[string]interface{})
+	for _, f := range collect(v, nil) {
+		value := f.v.Interface()
+		if f.secret() && !isZero(f.v) {
+			value = Redaction
+		}
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		result[f.name()] = value
+	}
+	return result
+}
+
+// field is a leaf of a config struct
+type field struct {
+	path []string
+	tag  reflect.StructTag
+	v    reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct
+}
+
+// collect returns the leaves of struct v
+func collect(v reflect.Value, path []string) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name, inline := yamlName(sf)
+		if name == "-" {
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), name)
+		if inline {
+			fieldPath = path
+		}
+
+		if isSection(sf.Type) {
+			fields = append(fields, collect(v.Field(i), fieldPath)...)
+			continue
+		}
+		fields = append(fields, field{path: fieldPath, tag: sf.Tag, v: v.Field(i)})
+	}
+	return fields
+}
+
+func yamlName(sf reflect.StructField) (name string, inline bool) {
+	parts := strings.Split(sf.Tag.Get("yaml"), ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			inline = true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], inline
+	}
+	return strings.ToLower(sf.Name), inline
+}
+
+func (f field) name() string {
+	return strings.Join(f.path, ".")
+}
+
+func (f field) env(prefix string) string {
+	if name := f.tag.Get("env"); name != "" {
+		return name
+	}
+	name := strings.ToUpper(strings.Join(f.path, "_"))
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return name
+}
+
+func (f field) usage(envPrefix string) string {
+	usage := f.tag.Get("usage")
+	if usage != "" {
+		usage += " "
+	}
+	return usage + "(env " + f.env(envPrefix) + ")"
+}
+
+func (f field) required() bool {
+	return f.tag.Get("required") == "true"
+}
+
+func (f field) secret() bool {
+	return f.tag.Get("secret") == "true"
+}
+
+// set parses s into the field, lists are separated by commas
+func (f field) set(s string) error {
+	v := f.v
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list).Convert(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("Unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// flagValue sets a field when the flag is given
+type flagValue struct {
+	f field
+}
+
+func (fv *flagValue) String() string {
+	if fv == nil || !fv.f.v.IsValid() || fv.f.secret() || isZero(fv.f.v) {
+		return ""
+	}
+	return fmt.Sprint(fv.f.v.Interface())
+}
+
+func (fv *flagValue) Set(s string) error {
+	return fv.f.set(s)
+}
+
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.f.v.Kind() == reflect.Bool
+}
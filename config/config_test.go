@@ -0,0 +1,176 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Service `yaml:",inline"`
+
+	Mail struct {
+		Sender   string        `yaml:"sender"`
+		Password string        `yaml:"password" secret:"true"`
+		Timeout  time.Duration `yaml:"timeout"`
+		Retries  int           `yaml:"retries"`
+	} `yaml:"mail"`
+	Debug string `yaml:"debug" env:"LOG_DEBUG"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func setenv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	yamlFile := writeFile(t, dir, "config.yaml", `
+server:
+  name: mail
+  port: "8080"
+  method_exceptions: [/mail.Mail/Ping]
+mail:
+  sender: noreply@example.com
+  timeout: 5s
+  retries: 2
+`)
+	jsonFile := writeFile(t, dir, "config.json", `{"mail": {"retries": 3}, "server": {"http_port": "8081"}}`)
+	defer setenv(t, map[string]string{
+		"APP_SERVER_PORT":             "9090",
+		"APP_MAIL_PASSWORD":           "hunter2",
+		"APP_SERVER_SHUTDOWN_TIMEOUT": "30s",
+		"LOG_DEBUG":                   "server",
+	})()
+
+	var cfg testConfig
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Mail.Retries = 1
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err = Load(&cfg,
+		WithFiles(yamlFile, jsonFile),
+		WithEnvPrefix("APP"),
+		WithFlags(fs, []string{"-server.port=7070", "-server.reflection"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "mail", cfg.Server.Name)
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host)
+	assert.Equal(t, "7070", cfg.Server.Port)
+	assert.Equal(t, "8081", cfg.Server.HTTPPort)
+	assert.True(t, cfg.Server.Reflection)
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, []string{"/mail.Mail/Ping"}, cfg.Server.MethodExceptions)
+	assert.Equal(t, "noreply@example.com", cfg.Mail.Sender)
+	assert.Equal(t, "hunter2", cfg.Mail.Password)
+	assert.Equal(t, 5*time.Second, cfg.Mail.Timeout)
+	assert.Equal(t, 3, cfg.Mail.Retries)
+	assert.Equal(t, "server", cfg.Debug)
+}
+
+func TestLoadTwice(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	args := []string{"-server.name=mail", "-server.port=7070"}
+
+	var first, second testConfig
+	require.NoError(t, Load(&first, WithFlags(fs, args)))
+	require.NoError(t, Load(&second, WithFlags(fs, append(args, "-mail.retries=2"))))
+	assert.Equal(t, "7070", second.Server.Port)
+	assert.Equal(t, 2, second.Mail.Retries)
+	assert.Equal(t, 0, first.Mail.Retries)
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("server.port", "", "")
+	assert.Error(t, Load(&first, WithFlags(fs, args)))
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var cfg testConfig
+	err = Load(&cfg, WithFiles(writeFile(t, dir, "config.yaml", "server:\n  nmae: mail\n")))
+	assert.Error(t, err)
+
+	defer setenv(t, map[string]string{
+		"SERVER_HTTP_PORT":     "8081",
+		"SERVER_SINGLE_PORT":   "true",
+		"SERVER_TLS_CERT_FILE": "tls.crt",
+		"MAIL_RETRIES":         "many",
+	})()
+	cfg = testConfig{}
+	err = Load(&cfg)
+	require.IsType(t, &Error{}, err)
+	assert.Equal(t, []string{
+		"MAIL_RETRIES: strconv.ParseInt: parsing \"many\": invalid syntax",
+		"server.name is required",
+		"server.port is required",
+		"server.http_port can not be used with server.single_port",
+		"server.tls.cert_file and server.tls.key_file must be set together",
+	}, err.(*Error).Problems)
+
+	assert.Error(t, Load(cfg))
+}
+
+func TestRedacted(t *testing.T) {
+	var cfg testConfig
+	cfg.Server.Name = "mail"
+	cfg.Redis.URL = "redis://:secret@localhost:6379"
+	cfg.Mail.Timeout = time.Second
+
+	redacted := Redacted(&cfg)
+	assert.Equal(t, "mail", redacted["server.name"])
+	assert.Equal(t, Redaction, redacted["redis.url"])
+	assert.Equal(t, "", redacted["mail.password"])
+	assert.Equal(t, "1s", redacted["mail.timeout"])
+}
+
+func TestServiceArgs(t *testing.T) {
+	cfg := Service{
+		Server: Server{
+			Name:       "mail",
+			Port:       "8080",
+			Reflection: true,
+			TLS:        TLS{CertFile: "tls.crt", KeyFile: "tls.key", MinVersion: "1.3"},
+		},
+		Redis:   Redis{URL: "redis://localhost:6379"},
+		Tracing: Tracing{Disabled: true},
+	}
+	args, closer, err := cfg.Args()
+	require.NoError(t, err)
+	assert.Equal(t, "mail", args.Name)
+	assert.Equal(t, "8080", args.Port)
+	assert.True(t, args.Reflection)
+	assert.Equal(t, []string{"tracing"}, args.DisableInterceptors)
+	require.NotNil(t, args.RedisStore)
+	assert.Nil(t, args.Registry)
+	require.NotNil(t, args.TLS)
+	assert.Equal(t, "tls.crt", args.TLS.CertFile)
+	assert.Equal(t, uint16(0x0304), args.TLS.MinVersion)
+
+	// The closer closes the redis pool
+	require.NoError(t, closer.Close())
+	assert.Error(t, args.RedisStore.Set("key", "value"))
+}
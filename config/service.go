@@ -0,0 +1,185 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/certs"
+	"github.com/go-xtek/vuvo-go/redis"
+	"github.com/go-xtek/vuvo-go/registry"
+	"github.com/go-xtek/vuvo-go/server"
+	"github.com/go-xtek/vuvo-go/tracing"
+)
+
+// Service is the config of a service built on server.Server, it is
+// embedded in the config struct of the service
+//
+//	type Config struct {
+//		config.Service `yaml:",inline"`
+//		Mail MailConfig `yaml:"mail"`
+//	}
+type Service struct {
+	Server   Server   `yaml:"server"`
+	Redis    Redis    `yaml:"redis"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Registry Registry `yaml:"registry"`
+}
+
+// Server configures server.Args
+type Server struct {
+	Name             string        `yaml:"name" required:"true"`
+	Version          string        `yaml:"version"`
+	Host             string        `yaml:"host"`
+	Port             string        `yaml:"port" required:"true" usage:"gRPC port"`
+	HTTPPort         string        `yaml:"http_port" usage:"gateway port, disabled when empty"`
+	AdminPort        string        `yaml:"admin_port" usage:"admin endpoints port, must not be public"`
+	SinglePort       bool          `yaml:"single_port" usage:"serve HTTP endpoints on the gRPC port"`
+	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout"`
+	Reflection       bool          `yaml:"reflection"`
+	MethodExceptions []string      `yaml:"method_exceptions" usage:"methods called without credentials"`
+	TLS              TLS           `yaml:"tls"`
+}
+
+// Validate implements Validator
+func (s *Server) Validate() error {
+	if s.SinglePort && s.HTTPPort != "" {
+		return errors.New("server.http_port can not be used with server.single_port")
+	}
+	return nil
+}
+
+// TLS configures certs.Config, TLS is enabled when CertFile is set
+type TLS struct {
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ClientCAFile   string        `yaml:"client_ca_file" usage:"enables mutual TLS"`
+	MinVersion     string        `yaml:"min_version" usage:"1.2 or 1.3"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+var tlsVersions = map[string]uint16{
+	"":    0,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Validate implements Validator
+func (t *TLS) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		return errors.New("server.tls.client_ca_file requires server.tls.cert_file")
+	}
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		return fmt.Errorf("Unknown server.tls.min_version %v", t.MinVersion)
+	}
+	return nil
+}
+
+// Redis configures redis.Store, it is not used when URL is empty
+type Redis struct {
+	URL string `yaml:"url" secret:"true" usage:"e.g. redis://:password@localhost:6379/0"`
+}
+
+// Tracing configures the jaeger tracer
+type Tracing struct {
+	Disabled      bool   `yaml:"disabled"`
+	JaegerAddress string `yaml:"jaeger_address" usage:"host:port of the jaeger agent"`
+}
+
+// Registry configures registration in consul, it is disabled when
+// ConsulAddress is empty
+type Registry struct {
+	ConsulAddress           string        `yaml:"consul_address"`
+	CheckTTL                time.Duration `yaml:"check_ttl"`
+	GRPCCheckInterval       time.Duration `yaml:"grpc_check_interval" usage:"let consul call grpc.health.v1 instead of a TTL check"`
+	DeregisterCriticalAfter time.Duration `yaml:"deregister_critical_after"`
+}
+
+// Args returns server.Args of the config, connecting to redis, the tracer
+// and consul when they are configured. Callers add the token generator
+// and other components, and close the returned closer after the server
+// is stopped.
+func (c *Service) Args() (_ server.Args, _ io.Closer, err error) {
+	var cl closers
+	defer func() {
+		if err != nil {
+			cl.Close()
+		}
+	}()
+
+	s := c.Server
+	args := server.Args{
+		Name:             s.Name,
+		Version:          s.Version,
+		Host:             s.Host,
+		Port:             s.Port,
+		HTTPPort:         s.HTTPPort,
+		AdminPort:        s.AdminPort,
+		SinglePort:       s.SinglePort,
+		ShutdownTimeout:  s.ShutdownTimeout,
+		Reflection:       s.Reflection,
+		MethodExceptions: s.MethodExceptions,
+		JaegerAddress:    c.Tracing.JaegerAddress,
+	}
+
+	if s.TLS.CertFile != "" {
+		args.TLS = &certs.Config{
+			CertFile:       s.TLS.CertFile,
+			KeyFile:        s.TLS.KeyFile,
+			ClientCAFile:   s.TLS.ClientCAFile,
+			MinVersion:     tlsVersions[s.TLS.MinVersion],
+			ReloadInterval: s.TLS.ReloadInterval,
+		}
+	}
+
+	if c.Redis.URL != "" {
+		args.RedisStore = redis.NewWithPool(c.Redis.URL)
+		if closer, ok := args.RedisStore.(io.Closer); ok {
+			cl = append(cl, closer)
+		}
+	}
+
+	if c.Tracing.Disabled {
+		args.DisableInterceptors = append(args.DisableInterceptors, server.InterceptorTracing)
+	} else {
+		tracer, closer, err := tracing.New(s.Name, c.Tracing.JaegerAddress)
+		if err != nil {
+			return server.Args{}, nil, err
+		}
+		args.Tracer = tracer
+		cl = append(cl, closer)
+	}
+
+	if c.Registry.ConsulAddress != "" {
+		client, err := registry.NewClient(c.Registry.ConsulAddress)
+		if err != nil {
+			return server.Args{}, nil, err
+		}
+		args.Registry = client
+		args.RegistryCheck = registry.Check{
+			TTL:                     c.Registry.CheckTTL,
+			GRPCInterval:            c.Registry.GRPCCheckInterval,
+			GRPCUseTLS:              s.TLS.CertFile != "",
+			DeregisterCriticalAfter: c.Registry.DeregisterCriticalAfter,
+		}
+	}
+	return args, cl, nil
+}
+
+// closers closes all its closers in reverse order, returning the first error
+type closers []io.Closer
+
+func (cl closers) Close() error {
+	var first error
+	for i := len(cl) - 1; i >= 0; i-- {
+		if err := cl[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -1,7 +1,10 @@
 package dialer
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	consul "github.com/hashicorp/consul/api"
@@ -9,6 +12,7 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // DialOption allows optional config for dialer
@@ -32,19 +36,62 @@ func WithBalancer(cc *consul.Client) DialOption {
 	}
 }
 
-// Dial returns a load balanced grpc client conn with tracing interceptor
-func Dial(name string, opts ...DialOption) (*grpc.ClientConn, error) {
-	dialopts := []grpc.DialOption{
-		grpc.WithInsecure(),
+// secureOption marks a dial option providing transport credentials
+type secureOption struct {
+	grpc.DialOption
+}
+
+// WithTLS dials with given TLS config instead of an insecure connection
+func WithTLS(cfg *tls.Config) DialOption {
+	return func(name string) (grpc.DialOption, error) {
+		return secureOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}, nil
+	}
+}
+
+// WithMutualTLS dials with a client certificate, verifying the server
+// certificate against given CA file
+func WithMutualTLS(certFile, keyFile, caFile string) DialOption {
+	return func(name string) (grpc.DialOption, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %v", caFile)
+		}
+
+		cfg := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      pool,
+		}
+		return WithTLS(cfg)(name)
 	}
+}
+
+// Dial returns a load balanced grpc client conn with tracing interceptor.
+// The connection is insecure unless WithTLS or WithMutualTLS is given.
+func Dial(name string, opts ...DialOption) (*grpc.ClientConn, error) {
+	var dialopts []grpc.DialOption
+	secure := false
 
 	for _, fn := range opts {
 		opt, err := fn(name)
 		if err != nil {
 			return nil, fmt.Errorf("config error: %v", err)
 		}
+		if _, ok := opt.(secureOption); ok {
+			secure = true
+		}
 		dialopts = append(dialopts, opt)
 	}
+	if !secure {
+		dialopts = append(dialopts, grpc.WithInsecure())
+	}
 
 	conn, err := grpc.Dial(name, dialopts...)
 	if err != nil {
@@ -0,0 +1,268 @@
+// Package gateway transcodes JSON HTTP requests to unary gRPC calls, so
+// browser clients can call gRPC services without generated gateway code.
+//
+// A method is called with POST /<package.Service>/<Method> and its request
+// message as JSON body, the response message is returned as JSON.
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+
+	"github.com/go-xtek/vuvo-go/l"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataHeaderPrefix is the prefix of HTTP headers forwarded as gRPC
+// metadata, as in grpc-gateway
+const MetadataHeaderPrefix = "Grpc-Metadata-"
+
+// MaxRequestSize is the maximum size in bytes of a request body, it matches
+// the default maximum message size of grpc.Server
+const MaxRequestSize = 4 << 20
+
+// Headers forwarded as gRPC metadata with their names lowercased
+var forwardedHeaders = []string{
+	"Authorization",
+	"X-Api-Key",
+	"Correlation-Id",
+}
+
+var ll = l.New()
+
+type method struct {
+	fullMethod string
+	in         reflect.Type
+	out        reflect.Type
+}
+
+// Gateway is an http.Handler calling gRPC methods on conn
+type Gateway struct {
+	conn    *grpc.ClientConn
+	methods map[string]method
+
+	marshaler   jsonpb.Marshaler
+	unmarshaler jsonpb.Unmarshaler
+}
+
+// New returns a gateway for unary methods of given services, which are
+// usually returned by grpc.Server.GetServiceInfo. Services whose
+// descriptors are not registered with golang/protobuf are skipped.
+func New(conn *grpc.ClientConn, services map[string]grpc.ServiceInfo) *Gateway {
+	g := &Gateway{
+		conn:      conn,
+		methods:   make(map[string]method),
+		marshaler: jsonpb.Marshaler{OrigName: true, EmitDefaults: true},
+	}
+	for name, info := range services {
+		file, ok := info.Metadata.(string)
+		if !ok {
+			continue
+		}
+		if err := g.addService(name, file); err != nil {
+			ll.Warn("Gateway: skip service", l.String("service", name), l.Error(err))
+		}
+	}
+	return g
+}
+
+func (g *Gateway) addService(name, file string) error {
+	fd, err := loadFileDescriptor(file)
+	if err != nil {
+		return err
+	}
+
+	for _, sd := range fd.Service {
+		if qualify(fd.GetPackage(), sd.GetName()) != name {
+			continue
+		}
+		for _, md := range sd.Method {
+			if md.GetClientStreaming() || md.GetServerStreaming() {
+				continue
+			}
+			in := proto.MessageType(strings.TrimPrefix(md.GetInputType(), "."))
+			out := proto.MessageType(strings.TrimPrefix(md.GetOutputType(), "."))
+			if in == nil || out == nil {
+				return fmt.Errorf("message types of %v are not registered", md.GetName())
+			}
+			fullMethod := "/" + name + "/" + md.GetName()
+			g.methods[fullMethod] = method{
+				fullMethod: fullMethod,
+				in:         in.Elem(),
+				out:        out.Elem(),
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("service not found in %v", file)
+}
+
+func loadFileDescriptor(file string) (*descriptor.FileDescriptorProto, error) {
+	gz := proto.FileDescriptor(file)
+	if gz == nil {
+		return nil, fmt.Errorf("file %v is not registered", file)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &descriptor.FileDescriptorProto{}
+	err = proto.Unmarshal(b, fd)
+	return fd, err
+}
+
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// Methods returns full names of methods served by the gateway
+func (g *Gateway) Methods() []string {
+	methods := make([]string, 0, len(g.methods))
+	for name := range g.methods {
+		methods = append(methods, name)
+	}
+	return methods
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m, ok := g.methods[r.URL.Path]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, codes.Unimplemented, "Method "+r.URL.Path+" not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, codes.Unimplemented, "Method not allowed")
+		return
+	}
+
+	req := reflect.New(m.in).Interface().(proto.Message)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	if err != nil && len(body) >= MaxRequestSize {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, codes.ResourceExhausted, "Request body too large")
+		return
+	}
+	if err != nil {
+		g.writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := g.unmarshaler.Unmarshal(bytes.NewReader(body), req); err != nil {
+			g.writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(r.Context(), incomingMetadata(r))
+	resp := reflect.New(m.out).Interface().(proto.Message)
+	var header metadata.MD
+	if err := g.conn.Invoke(ctx, m.fullMethod, req, resp, grpc.Header(&header)); err != nil {
+		g.writeError(w, err)
+		return
+	}
+
+	for k, vs := range header {
+		for _, v := range vs {
+			w.Header().Add(MetadataHeaderPrefix+k, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := g.marshaler.Marshal(w, resp); err != nil {
+		ll.Error("Gateway: unable to write response", l.String("method", m.fullMethod), l.Error(err))
+	}
+}
+
+// incomingMetadata returns metadata forwarded from HTTP headers
+func incomingMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for _, h := range forwardedHeaders {
+		if vs := r.Header[textproto.CanonicalMIMEHeaderKey(h)]; len(vs) > 0 {
+			md[strings.ToLower(h)] = vs
+		}
+	}
+	for k, vs := range r.Header {
+		if strings.HasPrefix(k, MetadataHeaderPrefix) {
+			md[strings.ToLower(k[len(MetadataHeaderPrefix):])] = vs
+		}
+	}
+
+	// Never forward the client IP claimed by the client
+	delete(md, "x-forwarded-for")
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		md["x-forwarded-for"] = []string{host}
+	}
+	return md
+}
+
+func (g *Gateway) writeError(w http.ResponseWriter, err error) {
+	s, _ := status.FromError(err)
+	writeJSONError(w, HTTPStatusFromCode(s.Code()), s.Code(), s.Message())
+}
+
+func writeJSONError(w http.ResponseWriter, httpStatus int, code codes.Code, msg string) {
+	type errorBody struct {
+		Error   string `json:"error"`
+		Code    int32  `json:"code"`
+		Message string `json:"message"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	err := json.NewEncoder(w).Encode(errorBody{Error: msg, Code: int32(code), Message: msg})
+	if err != nil {
+		ll.Error("Gateway: unable to write error", l.Error(err))
+	}
+}
+
+// HTTPStatusFromCode converts a gRPC code to HTTP status,
+// as in grpc-gateway
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGateway(t *testing.T, interceptor grpc.UnaryServerInterceptor) (*Gateway, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
+	hs := health.NewServer()
+	hs.SetServingStatus("foo", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+
+	return New(conn, s.GetServiceInfo()), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestGateway(t *testing.T) {
+	var md metadata.MD
+	g, stop := newTestGateway(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ = metadata.FromIncomingContext(ctx)
+		if len(md["authorization"]) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "Request login fail")
+		}
+		return handler(ctx, req)
+	})
+	defer stop()
+	assert.Equal(t, []string{"/grpc.health.v1.Health/Check"}, g.Methods(), "streaming methods are skipped")
+
+	call := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		return w
+	}
+	authHeader := http.Header{
+		"Authorization":       {"Bearer abc"},
+		"Grpc-Metadata-Trace": {"t1"},
+	}
+
+	w := call("POST", "/grpc.health.v1.Health/Check", `{"service": "foo"}`, authHeader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "NOT_SERVING"}`, w.Body.String())
+	assert.Equal(t, []string{"Bearer abc"}, md["authorization"])
+	assert.Equal(t, []string{"t1"}, md["trace"])
+	assert.Equal(t, []string{"192.0.2.1"}, md["x-forwarded-for"])
+
+	w = call("POST", "/grpc.health.v1.Health/Check", "", authHeader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "SERVING"}`, w.Body.String())
+
+	w = call("POST", "/grpc.health.v1.Health/Check", `{"service": "bar"}`, authHeader)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":5`)
+
+	w = call("POST", "/grpc.health.v1.Health/Check", `{"unknown": 1}`, authHeader)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call("POST", "/grpc.health.v1.Health/Check", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = call("POST", "/grpc.health.v1.Health/Check", `{"service": "`+strings.Repeat("a", MaxRequestSize)+`"}`, authHeader)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":8`)
+
+	w = call("GET", "/grpc.health.v1.Health/Check", "", authHeader)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = call("POST", "/grpc.health.v1.Health/Watch", "", authHeader)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,6 +2,8 @@ package grpc
 
 import (
 	"context"
+	"net"
+	"time"
 
 	"github.com/go-xtek/vuvo-go/auth"
 
@@ -9,11 +11,14 @@ import (
 
 	"github.com/go-xtek/vuvo-go/l"
 
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 var (
@@ -39,26 +44,78 @@ func LogUnaryServerInterceptor(logger l.Logger) grpc.UnaryServerInterceptor {
 			logger.Error(info.FullMethod, l.Interface("\n→", req), l.String("\n⇐ERROR", err.Error()))
 		}()
 
-		// Append correlation id
-		const correlationID = "correlation-id"
-		inMD, _ := metadata.FromIncomingContext(ctx)
-		var reqID string
-		if ids, ok := inMD[correlationID]; ok && len(ids) > 0 {
-			reqID = ids[0]
-		} else {
-			reqID = idgen.Generate(reqInfix).String()
-		}
-		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(correlationID, reqID))
+		return handler(withCorrelationID(ctx), req)
+	}
+}
 
-		return handler(ctx, req)
+// LogStreamServerInterceptor returns stream middleware for logging with zap
+func LogStreamServerInterceptor(logger l.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+		defer func() {
+			e := recover()
+			if e != nil {
+				logger.Error("Panic (Recovered)", l.Error(err), l.Stack())
+				err = grpc.Errorf(codes.Internal, "Internal Error (%v)", e)
+			}
+
+			if err == nil {
+				logger.Debug(info.FullMethod, l.Duration("duration", time.Since(start)))
+				return
+			}
+			logger.Error(info.FullMethod, l.Duration("duration", time.Since(start)), l.String("\n⇐ERROR", err.Error()))
+		}()
+
+		return handler(srv, wrapStream(ss, withCorrelationID(ss.Context())))
+	}
+}
+
+// withCorrelationID propagates correlation id of the request to outgoing
+// calls, or generates new one
+func withCorrelationID(ctx context.Context) context.Context {
+	const correlationID = "correlation-id"
+	inMD, _ := metadata.FromIncomingContext(ctx)
+	var reqID string
+	if ids, ok := inMD[correlationID]; ok && len(ids) > 0 {
+		reqID = ids[0]
+	} else {
+		reqID = idgen.Generate(reqInfix).String()
 	}
+	return metadata.NewOutgoingContext(ctx, metadata.Pairs(correlationID, reqID))
+}
+
+// wrapStream returns stream whose Context returns ctx
+func wrapStream(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	wrapped := grpc_middleware.WrapServerStream(ss)
+	wrapped.WrappedContext = ctx
+	return wrapped
 }
 
 // AuthFunc ...
 type AuthFunc func(ctx context.Context, fullMethod string) (context.Context, error)
 
+// AuthOption allows optional config for Authentication
+type AuthOption func(o *authOptions)
+
+type authOptions struct {
+	throttler auth.Throttler
+}
+
+// WithThrottler throttles clients sending invalid tokens before their
+// tokens hit the validator
+func WithThrottler(t auth.Throttler) AuthOption {
+	return func(o *authOptions) {
+		o.throttler = t
+	}
+}
+
 // Authentication ...
-func Authentication(validator auth.Validator, magicToken string, exceptions []string) AuthFunc {
+func Authentication(validator auth.Validator, magicToken string, exceptions []string, opts ...AuthOption) AuthFunc {
+	var o authOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+
 	return func(ctx context.Context, fullMethod string) (context.Context, error) {
 		for _, exception := range exceptions {
 			if exception == fullMethod {
@@ -66,6 +123,11 @@ func Authentication(validator auth.Validator, magicToken string, exceptions []st
 			}
 		}
 
+		// Already authenticated with an API key
+		if _, ok := auth.ProviderFromContext(ctx); ok {
+			return ctx, nil
+		}
+
 		tokenStr, err := grpc_auth.AuthFromMD(ctx, "Bearer")
 		if err != nil {
 			ll.Warn("No authorization header", l.String("method", fullMethod), l.Error(err))
@@ -78,16 +140,48 @@ func Authentication(validator auth.Validator, magicToken string, exceptions []st
 				TokenStr: "MAGIC",
 				UserID:   "",
 			}
-			return auth.NewContext(ctx, &auth.Claim{Token: token}), nil
+			return auth.NewContext(ctx, &auth.Claim{Token: token, Source: "magic"}), nil
+		}
+
+		ip := clientIP(ctx)
+		if o.throttler != nil {
+			delay, err := o.throttler.Allow(ip, tokenStr)
+			if err != nil {
+				ll.Warn("Blocked authentication", l.String("ip", ip), l.Error(err))
+				return ctx, grpc.Errorf(codes.ResourceExhausted, "Too many failed requests, try again later")
+			}
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx, ctx.Err()
+				}
+			}
 		}
 
-		token, err := validator.Validate(tokenStr)
+		var token auth.Token
+		var source string
+		if v, ok := validator.(auth.SourceValidator); ok {
+			token, source, err = v.ValidateWithSource(tokenStr)
+		} else {
+			token, err = validator.Validate(tokenStr)
+		}
+		if auth.IsTemporary(err) {
+			ll.Error("Unable to validate token", l.String("token", auth.Fingerprint(tokenStr)), l.Error(err))
+			return ctx, grpc.Errorf(codes.Unavailable, "Unable to validate token")
+		}
 		if err != nil {
-			ll.Warn("Invalid token", l.String("token", tokenStr), l.Error(err))
+			ll.Warn("Invalid token", l.String("token", auth.Fingerprint(tokenStr)), l.String("ip", ip), l.Error(err))
+			if o.throttler != nil {
+				o.throttler.Failure(ip, tokenStr)
+			}
 			return ctx, grpc.Errorf(codes.Unauthenticated, "Request login fail")
 		}
 
-		return auth.NewContext(ctx, &auth.Claim{Token: token}), nil
+		if token.IsDelegated() {
+			ll.Info("Delegated access", l.String("method", fullMethod), l.String("actor", token.ActorID), l.String("user", token.UserID))
+		}
+		return auth.NewContext(ctx, &auth.Claim{Token: token, Source: source}), nil
 	}
 }
 
@@ -101,3 +195,199 @@ func AuthUnaryServerInterceptor(authFunc AuthFunc) grpc.UnaryServerInterceptor {
 		return handler(newCtx, req)
 	}
 }
+
+// AuthStreamServerInterceptor authenticates streams with authFunc, the
+// claim is available from the context of the stream passed to handler
+func AuthStreamServerInterceptor(authFunc AuthFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		newCtx, err := authFunc(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, wrapStream(ss, newCtx))
+	}
+}
+
+// APIKeyHeader is the metadata key carrying API keys
+const APIKeyHeader = "x-api-key"
+
+// APIKeyUnaryServerInterceptor authenticates requests carrying an API key
+// and attaches the provider claim to the context. Requests without API key
+// are passed through, so it must be placed before AuthUnaryServerInterceptor.
+func APIKeyUnaryServerInterceptor(validator auth.APIKeyValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newCtx, err := authenticateAPIKey(ctx, validator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(newCtx, req)
+	}
+}
+
+// APIKeyStreamServerInterceptor is the stream equivalent of
+// APIKeyUnaryServerInterceptor
+func APIKeyStreamServerInterceptor(validator auth.APIKeyValidator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		newCtx, err := authenticateAPIKey(ss.Context(), validator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, wrapStream(ss, newCtx))
+	}
+}
+
+func authenticateAPIKey(ctx context.Context, validator auth.APIKeyValidator, fullMethod string) (context.Context, error) {
+	inMD, _ := metadata.FromIncomingContext(ctx)
+	keys := inMD[APIKeyHeader]
+	if len(keys) == 0 {
+		return ctx, nil
+	}
+
+	apiKey, err := validator.ValidateAPIKey(keys[0])
+	if err != nil {
+		ll.Warn("Invalid API key", l.String("method", fullMethod), l.String("key", auth.Fingerprint(keys[0])), l.Error(err))
+		return ctx, grpc.Errorf(codes.Unauthenticated, "Invalid API key")
+	}
+	if !apiKey.Allows(fullMethod) {
+		ll.Warn("API key is not allowed to call method", l.String("method", fullMethod), l.String("id", apiKey.ID))
+		return ctx, grpc.Errorf(codes.PermissionDenied, "API key is not allowed to call %v", fullMethod)
+	}
+	return auth.NewContextWithProvider(ctx, apiKey.Provider), nil
+}
+
+// PeerUnaryServerInterceptor authenticates requests from peers presenting a
+// verified client certificate which matches given rules, and attaches the
+// provider claim to the context. Other requests are passed through, so it
+// must be placed before AuthUnaryServerInterceptor.
+func PeerUnaryServerInterceptor(rules auth.PeerRules) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(authenticatePeer(ctx, rules, info.FullMethod), req)
+	}
+}
+
+// PeerStreamServerInterceptor is the stream equivalent of
+// PeerUnaryServerInterceptor
+func PeerStreamServerInterceptor(rules auth.PeerRules) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, wrapStream(ss, authenticatePeer(ss.Context(), rules, info.FullMethod)))
+	}
+}
+
+func authenticatePeer(ctx context.Context, rules auth.PeerRules, fullMethod string) context.Context {
+	identity := peerIdentity(ctx)
+	provider, ok := rules.Match(identity)
+	if !ok {
+		return ctx
+	}
+
+	ll.Debug("Authenticated with client certificate", l.String("method", fullMethod), l.String("identity", identity))
+	return auth.NewContextWithProvider(ctx, provider)
+}
+
+// clientIP returns IP address of the peer. Requests on connections of
+// InProcessListener, e.g. from the JSON gateway, carry it in x-forwarded-for.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if _, ok := p.AuthInfo.(inProcessAuthInfo); ok {
+		inMD, _ := metadata.FromIncomingContext(ctx)
+		if ips := inMD["x-forwarded-for"]; len(ips) > 0 {
+			return ips[0]
+		}
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// peerIdentity returns identity of the verified client certificate
+func peerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return auth.PeerIdentity(tlsInfo.State.VerifiedChains[0][0])
+}
+
+// RequireStepUpUnaryServerInterceptor rejects calls to given methods from
+// sessions which did not pass step-up authentication. It must be placed
+// after AuthUnaryServerInterceptor.
+func RequireStepUpUnaryServerInterceptor(methods []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkStepUp(ctx, methods, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RequireStepUpStreamServerInterceptor is the stream equivalent of
+// RequireStepUpUnaryServerInterceptor
+func RequireStepUpStreamServerInterceptor(methods []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkStepUp(ss.Context(), methods, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkStepUp(ctx context.Context, methods []string, fullMethod string) error {
+	for _, method := range methods {
+		if method != fullMethod {
+			continue
+		}
+		claim, ok := auth.FromContext(ctx)
+		if !ok || !claim.Token.StepUp {
+			return grpc.Errorf(codes.PermissionDenied, "Step-up authentication required")
+		}
+		break
+	}
+	return nil
+}
+
+// RequireScopeUnaryServerInterceptor restricts delegated tokens to methods
+// whose scope, given by full method name, they have. Delegated tokens can
+// not call methods without scope, other tokens are not restricted. It must
+// be placed after AuthUnaryServerInterceptor.
+func RequireScopeUnaryServerInterceptor(scopes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkScope(ctx, scopes, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RequireScopeStreamServerInterceptor is the stream equivalent of
+// RequireScopeUnaryServerInterceptor
+func RequireScopeStreamServerInterceptor(scopes map[string]string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkScope(ss.Context(), scopes, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkScope(ctx context.Context, scopes map[string]string, fullMethod string) error {
+	claim, ok := auth.FromContext(ctx)
+	if !ok || !claim.Token.IsDelegated() {
+		return nil
+	}
+	scope, ok := scopes[fullMethod]
+	if !ok || !claim.Token.HasScope(scope) {
+		ll.Warn("Delegated token is not allowed to call method", l.String("method", fullMethod), l.String("actor", claim.Token.ActorID))
+		return grpc.Errorf(codes.PermissionDenied, "Delegated token is not allowed to call %v", fullMethod)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthStreamServerInterceptor(T *testing.T) {
+	g := auth.NewGeneratorWithRepository("stream", auth.NewMemoryTokenRepository())
+	tok, err := g.Generate("user-alice", 100)
+	require.NoError(T, err)
+
+	interceptor := AuthStreamServerInterceptor(Authentication(g, "", nil))
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	T.Run("No credentials", func(t *testing.T) {
+		called := false
+		err := interceptor(nil, &testStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.False(t, called)
+	})
+
+	T.Run("Invalid token", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer invalid"))
+		err := interceptor(nil, &testStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return nil
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	T.Run("Claim in stream context", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tok.TokenStr))
+		var claim *auth.Claim
+		err := interceptor(nil, &testStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			claim, _ = auth.FromContext(ss.Context())
+			return nil
+		})
+		require.NoError(t, err)
+		require.NotNil(t, claim)
+		assert.Equal(t, "user-alice", claim.Token.UserID)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	md := metadata.Pairs("x-forwarded-for", "203.0.113.1")
+	bufAddr := &net.UnixAddr{Name: "bufconn", Net: "bufconn"}
+
+	// Only in-process connections are trusted to forward the client IP
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: bufAddr})
+	assert.Equal(t, "bufconn", clientIP(metadata.NewIncomingContext(ctx, md)))
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: bufAddr, AuthInfo: inProcessAuthInfo{}})
+	assert.Equal(t, "203.0.113.1", clientIP(metadata.NewIncomingContext(ctx, md)))
+	assert.Equal(t, "", clientIP(ctx))
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}})
+	assert.Equal(t, "192.0.2.1", clientIP(metadata.NewIncomingContext(ctx, md)))
+}
+
+// apiKeys is an auth.APIKeyValidator of fixed keys
+type apiKeys map[string]auth.APIKey
+
+func (keys apiKeys) ValidateAPIKey(key string) (auth.APIKey, error) {
+	apiKey, ok := keys[key]
+	if !ok {
+		return auth.APIKey{}, auth.ErrInvalidAPIKey
+	}
+	return apiKey, nil
+}
+
+func TestAPIKeyInterceptors(T *testing.T) {
+	validator := apiKeys{
+		"AK_partner.secret": {
+			ID:       "partner",
+			Provider: auth.ServiceProviderClaim{ID: "partner"},
+			Methods:  []string{"/test.Service/Allowed"},
+		},
+	}
+	unary := APIKeyUnaryServerInterceptor(validator)
+	stream := APIKeyStreamServerInterceptor(validator)
+	withKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, key))
+	}
+	call := func(ctx context.Context, method string) (context.Context, error) {
+		var got context.Context
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = ctx
+			return nil, nil
+		})
+		return got, err
+	}
+
+	T.Run("Valid key", func(t *testing.T) {
+		ctx, err := call(withKey("AK_partner.secret"), "/test.Service/Allowed")
+		require.NoError(t, err)
+		provider, ok := auth.ProviderFromContext(ctx)
+		require.True(t, ok)
+		assert.Equal(t, "partner", provider.ID)
+
+		var streamCtx context.Context
+		err = stream(nil, &testStream{ctx: withKey("AK_partner.secret")}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Allowed"}, func(srv interface{}, ss grpc.ServerStream) error {
+			streamCtx = ss.Context()
+			return nil
+		})
+		require.NoError(t, err)
+		_, ok = auth.ProviderFromContext(streamCtx)
+		assert.True(t, ok)
+	})
+
+	T.Run("Invalid key", func(t *testing.T) {
+		_, err := call(withKey("AK_partner.wrong"), "/test.Service/Allowed")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		err = stream(nil, &testStream{ctx: withKey("AK_partner.wrong")}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Allowed"}, func(srv interface{}, ss grpc.ServerStream) error {
+			return nil
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	T.Run("Method not allowed", func(t *testing.T) {
+		_, err := call(withKey("AK_partner.secret"), "/test.Service/Other")
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	T.Run("No key", func(t *testing.T) {
+		ctx, err := call(context.Background(), "/test.Service/Allowed")
+		require.NoError(t, err)
+		_, ok := auth.ProviderFromContext(ctx)
+		assert.False(t, ok)
+	})
+
+	T.Run("Authentication passes providers through", func(t *testing.T) {
+		ctx, err := call(withKey("AK_partner.secret"), "/test.Service/Allowed")
+		require.NoError(t, err)
+
+		authFunc := Authentication(auth.NewGeneratorWithRepository("apikey", auth.NewMemoryTokenRepository()), "", nil)
+		newCtx, err := authFunc(ctx, "/test.Service/Allowed")
+		require.NoError(t, err)
+		_, ok := auth.ProviderFromContext(newCtx)
+		assert.True(t, ok)
+
+		_, err = authFunc(context.Background(), "/test.Service/Allowed")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// errValidator returns err for all tokens
+type errValidator struct {
+	err error
+}
+
+func (v errValidator) Validate(tokenStr string) (auth.Token, error) {
+	return auth.Token{}, v.err
+}
+
+func TestAuthenticationValidatorErrors(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer garbage"))
+
+	_, err := Authentication(errValidator{errors.New("malformed token")}, "", nil)(ctx, "/test.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = Authentication(errValidator{auth.Temporary(errors.New("connection refused"))}, "", nil)(ctx, "/test.Service/Method")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+// testThrottler records failures and returns fixed results from Allow
+type testThrottler struct {
+	delay    time.Duration
+	err      error
+	failures []string
+}
+
+func (th *testThrottler) Allow(ip, tokenStr string) (time.Duration, error) {
+	return th.delay, th.err
+}
+
+func (th *testThrottler) Failure(ip, tokenStr string) {
+	th.failures = append(th.failures, ip+" "+tokenStr)
+}
+
+func TestAuthenticationWithThrottler(T *testing.T) {
+	g := auth.NewGeneratorWithRepository("throttle", auth.NewMemoryTokenRepository())
+	tok, err := g.Generate("user-alice", 100)
+	require.NoError(T, err)
+
+	withToken := func(ctx context.Context, tokenStr string) context.Context {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}})
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tokenStr))
+	}
+
+	T.Run("Banned", func(t *testing.T) {
+		th := &testThrottler{err: auth.ErrBanned}
+		_, err := Authentication(g, "", nil, WithThrottler(th))(withToken(context.Background(), tok.TokenStr), "/test.Service/Method")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	T.Run("Failure recorded", func(t *testing.T) {
+		th := &testThrottler{}
+		_, err := Authentication(g, "", nil, WithThrottler(th))(withToken(context.Background(), "invalid"), "/test.Service/Method")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, []string{"192.0.2.1 invalid"}, th.failures)
+
+		_, err = Authentication(g, "", nil, WithThrottler(th))(withToken(context.Background(), tok.TokenStr), "/test.Service/Method")
+		require.NoError(t, err)
+		assert.Len(t, th.failures, 1)
+	})
+
+	T.Run("Delayed", func(t *testing.T) {
+		th := &testThrottler{delay: 20 * time.Millisecond}
+		start := time.Now()
+		_, err := Authentication(g, "", nil, WithThrottler(th))(withToken(context.Background(), tok.TokenStr), "/test.Service/Method")
+		require.NoError(t, err)
+		assert.True(t, time.Since(start) >= th.delay)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		th.delay = time.Minute
+		_, err = Authentication(g, "", nil, WithThrottler(th))(withToken(ctx, tok.TokenStr), "/test.Service/Method")
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	scopes := map[string]string{"/test.Orders/List": "orders:read"}
+	unary := RequireScopeUnaryServerInterceptor(scopes)
+	stream := RequireScopeStreamServerInterceptor(scopes)
+	call := func(token auth.Token, method string) error {
+		ctx := auth.NewContext(context.Background(), &auth.Claim{Token: token})
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	delegated := auth.Token{UserID: "customer", ActorID: "support", Scopes: []string{"orders:read"}}
+
+	assert.NoError(t, call(delegated, "/test.Orders/List"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(delegated, "/test.Orders/Cancel")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(auth.Token{UserID: "customer", ActorID: "support"}, "/test.Orders/List")))
+
+	// Other tokens are not restricted
+	assert.NoError(t, call(auth.Token{UserID: "customer"}, "/test.Orders/Cancel"))
+
+	ctx := auth.NewContext(context.Background(), &auth.Claim{Token: delegated})
+	err := stream(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/test.Orders/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
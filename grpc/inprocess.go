@@ -0,0 +1,86 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// InProcessListener marks connections accepted from lis as in-process, e.g.
+// of the JSON gateway. Requests on them are trusted to carry the client IP
+// in x-forwarded-for, so lis must not be reachable from outside the process.
+func InProcessListener(lis net.Listener) net.Listener {
+	return inProcessListener{lis}
+}
+
+type inProcessListener struct {
+	net.Listener
+}
+
+func (l inProcessListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return inProcessConn{conn}, nil
+}
+
+type inProcessConn struct {
+	net.Conn
+}
+
+// inProcessAuthInfo is the AuthInfo of in-process connections, it can only be
+// set by serverCredentials
+type inProcessAuthInfo struct{}
+
+func (inProcessAuthInfo) AuthType() string {
+	return "in-process"
+}
+
+// ServerCredentials returns credentials serving connections of
+// InProcessListener without TLS and without a peer identity. Other
+// connections are served with tlsCreds, or without TLS when it is nil.
+func ServerCredentials(tlsCreds credentials.TransportCredentials) credentials.TransportCredentials {
+	return &serverCredentials{tls: tlsCreds}
+}
+
+type serverCredentials struct {
+	tls credentials.TransportCredentials
+}
+
+func (c *serverCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("ServerCredentials can not be used by clients")
+}
+
+func (c *serverCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if _, ok := conn.(inProcessConn); ok {
+		return conn, inProcessAuthInfo{}, nil
+	}
+	if c.tls == nil {
+		return conn, nil, nil
+	}
+	return c.tls.ServerHandshake(conn)
+}
+
+func (c *serverCredentials) Info() credentials.ProtocolInfo {
+	if c.tls == nil {
+		return credentials.ProtocolInfo{}
+	}
+	return c.tls.Info()
+}
+
+func (c *serverCredentials) Clone() credentials.TransportCredentials {
+	if c.tls == nil {
+		return &serverCredentials{}
+	}
+	return &serverCredentials{tls: c.tls.Clone()}
+}
+
+func (c *serverCredentials) OverrideServerName(name string) error {
+	if c.tls == nil {
+		return nil
+	}
+	return c.tls.OverrideServerName(name)
+}
@@ -0,0 +1,205 @@
+// Package health implements grpc.health.v1 with the serving status driven by
+// dependency checkers
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/redis"
+	"github.com/go-xtek/vuvo-go/registry"
+
+	"google.golang.org/grpc"
+	grpc_health "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Default values of Config
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultTimeout  = 2 * time.Second
+)
+
+// Methods of the health service, they are called by probes
+// without credentials
+var Methods = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+}
+
+var ll = l.New()
+
+// Checker returns an error if a dependency is not usable
+type Checker func(ctx context.Context) error
+
+// Redis returns a checker sending PING to redis, stores not implementing
+// redis.Pinger are checked with a GET
+func Redis(r redis.Store) Checker {
+	ping := func() error {
+		_, err := r.GetString("health:ping")
+		return err
+	}
+	if p, ok := r.(redis.Pinger); ok {
+		ping = p.Ping
+	}
+	return func(ctx context.Context) error {
+		return withContext(ctx, ping)
+	}
+}
+
+// Consul returns a checker reaching the consul agent
+func Consul(c *registry.Client) Checker {
+	return func(ctx context.Context) error {
+		return withContext(ctx, func() error {
+			_, err := c.Agent().Self()
+			return err
+		})
+	}
+}
+
+// withContext runs fn, which does not accept a context, until ctx is done
+func withContext(ctx context.Context, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Config ...
+type Config struct {
+	// Checkers are run every Interval, each one for at most Timeout.
+	// The server is SERVING when all of them succeed.
+	Checkers map[string]Checker
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Server implements grpc.health.v1 for the whole server, i.e. the
+// empty service name
+type Server struct {
+	*grpc_health.Server
+
+	cfg Config
+
+	mu       sync.Mutex
+	results  map[string]error
+	shutdown bool
+}
+
+// NewServer returns new health server. It is NOT_SERVING until the first
+// run of the checkers, or SERVING if there is no checker.
+func NewServer(cfg Config) *Server {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	s := &Server{
+		Server:  grpc_health.NewServer(),
+		cfg:     cfg,
+		results: make(map[string]error),
+	}
+	if len(cfg.Checkers) > 0 {
+		s.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return s
+}
+
+// Register registers the gRPC service, it can be passed to server.RegisterServer
+func (s *Server) Register(g *grpc.Server) error {
+	healthpb.RegisterHealthServer(g, s)
+	return nil
+}
+
+// Run runs the checkers every interval until ctx is done
+func (s *Server) Run(ctx context.Context) {
+	if len(s.cfg.Checkers) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.CheckNow(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckNow runs all checkers concurrently, updates the serving status and
+// returns the error of each checker
+func (s *Server) CheckNow(ctx context.Context) map[string]error {
+	results := make(map[string]error, len(s.cfg.Checkers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range s.cfg.Checkers {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+			defer cancel()
+
+			err := checker(checkCtx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	status := healthpb.HealthCheckResponse_SERVING
+	for name, err := range results {
+		if err != nil {
+			ll.Warn("Health check failed", l.String("checker", name), l.Error(err))
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = results
+	if !s.shutdown {
+		s.SetServingStatus("", status)
+	}
+	return results
+}
+
+// Results returns the error of each checker in the last run
+func (s *Server) Results() map[string]error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make(map[string]error, len(s.results))
+	for name, err := range s.results {
+		results[name] = err
+	}
+	return results
+}
+
+// Serving reports whether the server is SERVING
+func (s *Server) Serving() bool {
+	resp, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+}
+
+// Shutdown sets NOT_SERVING permanently, it must be called as soon as the
+// server starts shutting down so load balancers stop sending requests
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdown = true
+	s.Server.Shutdown()
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/redis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	var redisErr error
+	s := NewServer(Config{
+		Checkers: map[string]Checker{
+			"redis": func(ctx context.Context) error { return redisErr },
+			"other": func(ctx context.Context) error { return nil },
+		},
+	})
+	assert.False(t, s.Serving(), "not serving before the first check")
+
+	results := s.CheckNow(context.Background())
+	assert.Equal(t, map[string]error{"redis": nil, "other": nil}, results)
+	assert.True(t, s.Serving())
+
+	redisErr = errors.New("connection refused")
+	s.CheckNow(context.Background())
+	assert.False(t, s.Serving())
+	assert.Equal(t, redisErr, s.Results()["redis"])
+
+	redisErr = nil
+	s.CheckNow(context.Background())
+	assert.True(t, s.Serving())
+
+	s.Shutdown()
+	assert.False(t, s.Serving())
+	s.CheckNow(context.Background())
+	assert.False(t, s.Serving(), "not serving after shutdown")
+}
+
+func TestTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	s := NewServer(Config{
+		Checkers: map[string]Checker{
+			"slow": func(ctx context.Context) error {
+				return withContext(ctx, func() error {
+					<-block
+					return nil
+				})
+			},
+		},
+		Timeout: 10 * time.Millisecond,
+	})
+	results := s.CheckNow(context.Background())
+	assert.Equal(t, context.DeadlineExceeded, results["slow"])
+	assert.False(t, s.Serving())
+}
+
+func TestNoChecker(t *testing.T) {
+	s := NewServer(Config{})
+	assert.True(t, s.Serving())
+}
+
+func TestRedisUnreachable(t *testing.T) {
+	s := NewServer(Config{
+		Checkers: map[string]Checker{
+			"redis": Redis(redis.NewWithPool("redis://127.0.0.1:1")),
+		},
+		Timeout: 5 * time.Second,
+	})
+	results := s.CheckNow(context.Background())
+	assert.Error(t, results["redis"])
+	assert.False(t, s.Serving())
+}
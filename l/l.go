@@ -29,6 +29,9 @@ type Logger struct {
 	zap.Logger
 }
 
+// Field is a marshaling operation used to add a key-value pair to a logger's context
+type Field = zap.Field
+
 // Short-hand functions for logging.
 var (
 	Base64    = zap.Base64
@@ -0,0 +1,59 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ServeHTTP implements the token endpoint. Clients authenticate with HTTP
+// Basic authentication or client_id and client_secret form parameters.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, ErrInvalidRequest.with("only POST is supported"))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrInvalidRequest.with(err.Error()))
+		return
+	}
+
+	req := &TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientId:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Username:     r.PostForm.Get("username"),
+		Password:     r.PostForm.Get("password"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Code:         r.PostForm.Get("code"),
+		RedirectUri:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		Scope:        r.PostForm.Get("scope"),
+	}
+	if id, secret, ok := r.BasicAuth(); ok {
+		req.ClientId, req.ClientSecret = id, secret
+	}
+
+	resp, err := s.token(req)
+	if err != nil {
+		code := http.StatusBadRequest
+		switch err.Code {
+		case ErrInvalidClient.Code:
+			code = http.StatusUnauthorized
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		case ErrServer.Code:
+			code = http.StatusInternalServerError
+		}
+		writeJSON(w, code, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,410 @@
+// Package oauth2 issues tokens with standard OAuth2 grants (RFC 6749),
+// backed by auth.Generator
+package oauth2
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:.. -I.. oauth2/oauth2.proto
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/l"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Grant types
+const (
+	GrantPassword          = "password"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+	GrantAuthorizationCode = "authorization_code"
+)
+
+// PKCE code challenge methods
+const (
+	ChallengePlain = "plain"
+	ChallengeS256  = "S256"
+)
+
+const (
+	// DefaultAccessTokenTTL in seconds
+	DefaultAccessTokenTTL = 60 * 60
+
+	// DefaultRefreshTokenTTL in seconds
+	DefaultRefreshTokenTTL = auth.DefaultTTL
+
+	// DefaultCodeTTL in seconds
+	DefaultCodeTTL = 60
+
+	// TokenMethod is the full gRPC method name of the token endpoint,
+	// which should be added to server method exceptions
+	TokenMethod = "/vuvo.oauth2.OAuth2/Token"
+)
+
+var ll = l.New()
+
+// Client is a registered OAuth2 client
+type Client struct {
+	ID           string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+
+	// Public clients, e.g. mobile apps, can not keep a secret. They must use
+	// PKCE with authorization_code grant.
+	Public bool
+}
+
+// ClientVerifier authenticates OAuth2 clients. For public clients the
+// secret is empty.
+type ClientVerifier interface {
+	VerifyClient(clientID, clientSecret string) (Client, error)
+}
+
+// UserVerifier authenticates resource owners with password grant
+type UserVerifier interface {
+	VerifyUser(username, password string) (userID string, err error)
+}
+
+// Config ...
+type Config struct {
+	// AccessTokens issues access tokens, usually the generator used by
+	// server.Args to validate requests
+	AccessTokens auth.Generator
+
+	// RefreshTokens and Codes should use generator names different from
+	// AccessTokens, so they can not be used as access tokens
+	RefreshTokens auth.Generator
+	Codes         auth.Generator
+
+	Clients ClientVerifier
+	Users   UserVerifier
+
+	AccessTokenTTL  int
+	RefreshTokenTTL int
+	CodeTTL         int
+}
+
+// Server implements OAuth2Server and http.Handler
+type Server struct {
+	cfg Config
+}
+
+// NewServer returns new OAuth2 server
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.AccessTokens == nil || cfg.RefreshTokens == nil || cfg.Codes == nil {
+		return nil, errors.New("OAuth2: AccessTokens, RefreshTokens and Codes generators required")
+	}
+	if cfg.Clients == nil {
+		return nil, errors.New("OAuth2: Clients verifier required")
+	}
+	// Codes and refresh tokens are single use
+	_, codesOK := cfg.Codes.(auth.Consumer)
+	_, refreshOK := cfg.RefreshTokens.(auth.Consumer)
+	if !codesOK || !refreshOK {
+		return nil, errors.New("OAuth2: RefreshTokens and Codes generators must implement auth.Consumer")
+	}
+	if cfg.AccessTokenTTL == 0 {
+		cfg.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL == 0 {
+		cfg.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	if cfg.CodeTTL == 0 {
+		cfg.CodeTTL = DefaultCodeTTL
+	}
+	return &Server{cfg: cfg}, nil
+}
+
+// Register registers the gRPC service, it can be passed to server.RegisterServer
+func (s *Server) Register(g *grpc.Server) error {
+	RegisterOAuth2Server(g, s)
+	return nil
+}
+
+// Token implements OAuth2Server
+func (s *Server) Token(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	resp, err := s.token(req)
+	if err != nil {
+		return nil, status.Error(err.grpcCode(), err.Error())
+	}
+	return resp, nil
+}
+
+// IssueCode creates an authorization code for given user, after the user
+// has authenticated and approved the client. redirectURI must be registered
+// for the client, codeChallenge is required for public clients.
+func (s *Server) IssueCode(client Client, userID, redirectURI, scope, codeChallenge, challengeMethod string) (string, error) {
+	if !contains(client.GrantTypes, GrantAuthorizationCode) {
+		return "", ErrUnauthorizedClient
+	}
+	if !contains(client.RedirectURIs, redirectURI) {
+		return "", ErrInvalidRequest.with("unregistered redirect_uri")
+	}
+	if codeChallenge != "" && challengeMethod == "" {
+		challengeMethod = ChallengePlain
+	}
+	if challengeMethod != "" && challengeMethod != ChallengePlain && challengeMethod != ChallengeS256 {
+		return "", ErrInvalidRequest.with("unsupported code_challenge_method")
+	}
+
+	value := url.Values{
+		"client_id":             {client.ID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {scope},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {challengeMethod},
+	}
+	t, err := s.cfg.Codes.GenerateWithValue(userID, value.Encode(), s.cfg.CodeTTL)
+	if err != nil {
+		return "", err
+	}
+	return t.TokenStr, nil
+}
+
+// ParseValue returns client id and scope attached to an access token
+// issued by Server
+func ParseValue(t auth.Token) (clientID, scope string) {
+	v, _ := url.ParseQuery(t.Value)
+	return v.Get("client_id"), v.Get("scope")
+}
+
+func (s *Server) token(req *TokenRequest) (*TokenResponse, *Error) {
+	if req.GrantType == "" {
+		return nil, ErrInvalidRequest.with("grant_type required")
+	}
+
+	client, err := s.cfg.Clients.VerifyClient(req.ClientId, req.ClientSecret)
+	if err != nil {
+		ll.Warn("OAuth2: invalid client", l.String("client_id", req.ClientId), l.Error(err))
+		return nil, ErrInvalidClient
+	}
+	if !contains(client.GrantTypes, req.GrantType) {
+		return nil, ErrUnauthorizedClient
+	}
+
+	switch req.GrantType {
+	case GrantPassword:
+		return s.grantPassword(client, req)
+	case GrantClientCredentials:
+		return s.grantClientCredentials(client, req)
+	case GrantRefreshToken:
+		return s.grantRefreshToken(client, req)
+	case GrantAuthorizationCode:
+		return s.grantAuthorizationCode(client, req)
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+func (s *Server) grantPassword(client Client, req *TokenRequest) (*TokenResponse, *Error) {
+	if s.cfg.Users == nil {
+		return nil, ErrUnsupportedGrantType
+	}
+	if req.Username == "" || req.Password == "" {
+		return nil, ErrInvalidRequest.with("username and password required")
+	}
+	if !allowScope(client, req.Scope) {
+		return nil, ErrInvalidScope
+	}
+
+	userID, err := s.cfg.Users.VerifyUser(req.Username, req.Password)
+	if err != nil {
+		return nil, ErrInvalidGrant.with("invalid username or password")
+	}
+	return s.issue(client, userID, req.Scope, true)
+}
+
+func (s *Server) grantClientCredentials(client Client, req *TokenRequest) (*TokenResponse, *Error) {
+	if client.Public {
+		return nil, ErrUnauthorizedClient
+	}
+	if !allowScope(client, req.Scope) {
+		return nil, ErrInvalidScope
+	}
+	return s.issue(client, "", req.Scope, false)
+}
+
+func (s *Server) grantRefreshToken(client Client, req *TokenRequest) (*TokenResponse, *Error) {
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidRequest.with("refresh_token required")
+	}
+
+	t, err := s.cfg.RefreshTokens.Validate(req.RefreshToken)
+	if err == auth.ErrInvalid {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, ErrServer
+	}
+	clientID, scope := ParseValue(t)
+	if clientID != client.ID {
+		return nil, ErrInvalidGrant
+	}
+
+	// A narrower scope may be requested
+	if req.Scope != "" {
+		if !isSubset(strings.Fields(req.Scope), strings.Fields(scope)) {
+			return nil, ErrInvalidScope
+		}
+		scope = req.Scope
+	}
+
+	// Rotate refresh token, only one of concurrent requests wins
+	_, err = s.cfg.RefreshTokens.(auth.Consumer).Consume(req.RefreshToken)
+	if err == auth.ErrInvalid {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, ErrServer
+	}
+	return s.issue(client, t.UserID, scope, true)
+}
+
+func (s *Server) grantAuthorizationCode(client Client, req *TokenRequest) (*TokenResponse, *Error) {
+	if req.Code == "" {
+		return nil, ErrInvalidRequest.with("code required")
+	}
+
+	// Codes are single use
+	t, err := s.cfg.Codes.(auth.Consumer).Consume(req.Code)
+	if err == auth.ErrInvalid {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, ErrServer
+	}
+
+	v, _ := url.ParseQuery(t.Value)
+	if v.Get("client_id") != client.ID || v.Get("redirect_uri") != req.RedirectUri {
+		return nil, ErrInvalidGrant
+	}
+
+	challenge := v.Get("code_challenge")
+	if challenge == "" {
+		if client.Public {
+			return nil, ErrInvalidGrant.with("PKCE required for public clients")
+		}
+	} else if !verifyPKCE(challenge, v.Get("code_challenge_method"), req.CodeVerifier) {
+		return nil, ErrInvalidGrant.with("invalid code_verifier")
+	}
+
+	return s.issue(client, t.UserID, v.Get("scope"), true)
+}
+
+func (s *Server) issue(client Client, userID, scope string, withRefresh bool) (*TokenResponse, *Error) {
+	value := url.Values{
+		"client_id": {client.ID},
+		"scope":     {scope},
+	}.Encode()
+
+	access, err := s.cfg.AccessTokens.GenerateWithValue(userID, value, s.cfg.AccessTokenTTL)
+	if err != nil {
+		ll.Error("OAuth2: unable to generate access token", l.Error(err))
+		return nil, ErrServer
+	}
+	resp := &TokenResponse{
+		AccessToken: access.TokenStr,
+		TokenType:   "Bearer",
+		ExpiresIn:   int32(s.cfg.AccessTokenTTL),
+		Scope:       scope,
+	}
+	if !withRefresh || !contains(client.GrantTypes, GrantRefreshToken) {
+		return resp, nil
+	}
+
+	refresh, err := s.cfg.RefreshTokens.GenerateWithValue(userID, value, s.cfg.RefreshTokenTTL)
+	if err != nil {
+		ll.Error("OAuth2: unable to generate refresh token", l.Error(err))
+		return nil, ErrServer
+	}
+	resp.RefreshToken = refresh.TokenStr
+	return resp, nil
+}
+
+func verifyPKCE(challenge, method, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	computed := verifier
+	if method == ChallengeS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		computed = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func allowScope(client Client, scope string) bool {
+	if len(client.Scopes) == 0 {
+		return true
+	}
+	return isSubset(strings.Fields(scope), client.Scopes)
+}
+
+func isSubset(items, set []string) bool {
+	for _, item := range items {
+		if !contains(set, item) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is an OAuth2 error response
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// OAuth2 errors as described in RFC 6749 section 5.2
+var (
+	ErrInvalidRequest       = &Error{Code: "invalid_request"}
+	ErrInvalidClient        = &Error{Code: "invalid_client"}
+	ErrInvalidGrant         = &Error{Code: "invalid_grant"}
+	ErrUnauthorizedClient   = &Error{Code: "unauthorized_client"}
+	ErrUnsupportedGrantType = &Error{Code: "unsupported_grant_type"}
+	ErrInvalidScope         = &Error{Code: "invalid_scope"}
+	ErrServer               = &Error{Code: "server_error"}
+)
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func (e *Error) with(description string) *Error {
+	return &Error{Code: e.Code, Description: description}
+}
+
+func (e *Error) grpcCode() codes.Code {
+	switch e.Code {
+	case ErrInvalidClient.Code:
+		return codes.Unauthenticated
+	case ErrUnauthorizedClient.Code:
+		return codes.PermissionDenied
+	case ErrServer.Code:
+		return codes.Internal
+	default:
+		return codes.InvalidArgument
+	}
+}
@@ -0,0 +1,324 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: oauth2/oauth2.proto
+
+package oauth2
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type TokenRequest struct {
+	GrantType string `protobuf:"bytes,1,opt,name=grant_type,json=grantType,proto3" json:"grant_type,omitempty"`
+	// Client authentication
+	ClientId     string `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	ClientSecret string `protobuf:"bytes,3,opt,name=client_secret,json=clientSecret,proto3" json:"client_secret,omitempty"`
+	// password grant
+	Username string `protobuf:"bytes,4,opt,name=username,proto3" json:"username,omitempty"`
+	Password string `protobuf:"bytes,5,opt,name=password,proto3" json:"password,omitempty"`
+	// refresh_token grant
+	RefreshToken string `protobuf:"bytes,6,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	// authorization_code grant
+	Code                 string   `protobuf:"bytes,7,opt,name=code,proto3" json:"code,omitempty"`
+	RedirectUri          string   `protobuf:"bytes,8,opt,name=redirect_uri,json=redirectUri,proto3" json:"redirect_uri,omitempty"`
+	CodeVerifier         string   `protobuf:"bytes,9,opt,name=code_verifier,json=codeVerifier,proto3" json:"code_verifier,omitempty"`
+	Scope                string   `protobuf:"bytes,10,opt,name=scope,proto3" json:"scope,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenRequest) Reset()         { *m = TokenRequest{} }
+func (m *TokenRequest) String() string { return proto.CompactTextString(m) }
+func (*TokenRequest) ProtoMessage()    {}
+func (*TokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f159a6fd05c554a4, []int{0}
+}
+
+func (m *TokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenRequest.Unmarshal(m, b)
+}
+func (m *TokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenRequest.Marshal(b, m, deterministic)
+}
+func (m *TokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenRequest.Merge(m, src)
+}
+func (m *TokenRequest) XXX_Size() int {
+	return xxx_messageInfo_TokenRequest.Size(m)
+}
+func (m *TokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenRequest proto.InternalMessageInfo
+
+func (m *TokenRequest) GetGrantType() string {
+	if m != nil {
+		return m.GrantType
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetClientSecret() string {
+	if m != nil {
+		return m.ClientSecret
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetRefreshToken() string {
+	if m != nil {
+		return m.RefreshToken
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetRedirectUri() string {
+	if m != nil {
+		return m.RedirectUri
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetCodeVerifier() string {
+	if m != nil {
+		return m.CodeVerifier
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetScope() string {
+	if m != nil {
+		return m.Scope
+	}
+	return ""
+}
+
+type TokenResponse struct {
+	AccessToken          string   `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	TokenType            string   `protobuf:"bytes,2,opt,name=token_type,json=tokenType,proto3" json:"token_type,omitempty"`
+	ExpiresIn            int32    `protobuf:"varint,3,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"`
+	RefreshToken         string   `protobuf:"bytes,4,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	Scope                string   `protobuf:"bytes,5,opt,name=scope,proto3" json:"scope,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenResponse) Reset()         { *m = TokenResponse{} }
+func (m *TokenResponse) String() string { return proto.CompactTextString(m) }
+func (*TokenResponse) ProtoMessage()    {}
+func (*TokenResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f159a6fd05c554a4, []int{1}
+}
+
+func (m *TokenResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenResponse.Unmarshal(m, b)
+}
+func (m *TokenResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenResponse.Marshal(b, m, deterministic)
+}
+func (m *TokenResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenResponse.Merge(m, src)
+}
+func (m *TokenResponse) XXX_Size() int {
+	return xxx_messageInfo_TokenResponse.Size(m)
+}
+func (m *TokenResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenResponse proto.InternalMessageInfo
+
+func (m *TokenResponse) GetAccessToken() string {
+	if m != nil {
+		return m.AccessToken
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetTokenType() string {
+	if m != nil {
+		return m.TokenType
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetExpiresIn() int32 {
+	if m != nil {
+		return m.ExpiresIn
+	}
+	return 0
+}
+
+func (m *TokenResponse) GetRefreshToken() string {
+	if m != nil {
+		return m.RefreshToken
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetScope() string {
+	if m != nil {
+		return m.Scope
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*TokenRequest)(nil), "vuvo.oauth2.TokenRequest")
+	proto.RegisterType((*TokenResponse)(nil), "vuvo.oauth2.TokenResponse")
+}
+
+func init() { proto.RegisterFile("oauth2/oauth2.proto", fileDescriptor_f159a6fd05c554a4) }
+
+var fileDescriptor_f159a6fd05c554a4 = []byte{
+	// 352 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x92, 0xc1, 0x4e, 0xea, 0x50,
+	0x10, 0x86, 0x03, 0x97, 0xf6, 0xb6, 0x03, 0x6c, 0xce, 0xbd, 0x8b, 0x23, 0x86, 0x44, 0x71, 0xe3,
+	0xaa, 0x26, 0xb8, 0x37, 0xd1, 0x95, 0xac, 0x4c, 0x10, 0x5d, 0xb8, 0x69, 0x6a, 0x3b, 0xc8, 0x89,
+	0xda, 0x53, 0xe7, 0x9c, 0xa2, 0x3c, 0x93, 0xef, 0xe0, 0xb3, 0x99, 0xce, 0x14, 0x43, 0x22, 0xab,
+	0x76, 0xbe, 0x7f, 0x32, 0xfd, 0xe7, 0xef, 0xc0, 0x3f, 0x9b, 0xd5, 0x7e, 0x35, 0x3d, 0x93, 0x47,
+	0x52, 0x91, 0xf5, 0x56, 0xf5, 0xd7, 0xf5, 0xda, 0x26, 0x82, 0x26, 0x5f, 0x5d, 0x18, 0x2c, 0xec,
+	0x33, 0x96, 0x73, 0x7c, 0xab, 0xd1, 0x79, 0x35, 0x06, 0x78, 0xa2, 0xac, 0xf4, 0xa9, 0xdf, 0x54,
+	0xa8, 0x3b, 0x47, 0x9d, 0xd3, 0x78, 0x1e, 0x33, 0x59, 0x6c, 0x2a, 0x54, 0x87, 0x10, 0xe7, 0x2f,
+	0x06, 0x4b, 0x9f, 0x9a, 0x42, 0x77, 0x59, 0x8d, 0x04, 0xcc, 0x0a, 0x75, 0x02, 0xc3, 0x56, 0x74,
+	0x98, 0x13, 0x7a, 0xfd, 0x87, 0x1b, 0x06, 0x02, 0x6f, 0x99, 0xa9, 0x11, 0x44, 0xb5, 0x43, 0x2a,
+	0xb3, 0x57, 0xd4, 0x3d, 0x19, 0xb0, 0xad, 0x1b, 0xad, 0xca, 0x9c, 0x7b, 0xb7, 0x54, 0xe8, 0x40,
+	0xb4, 0x6d, 0xdd, 0x0c, 0x27, 0x5c, 0x12, 0xba, 0x55, 0xea, 0x1b, 0xc3, 0x3a, 0x94, 0xe1, 0x2d,
+	0xe4, 0x25, 0x94, 0x82, 0x5e, 0x6e, 0x0b, 0xd4, 0x7f, 0x59, 0xe3, 0x77, 0x75, 0x0c, 0x03, 0xc2,
+	0xc2, 0x10, 0xe6, 0x3e, 0xad, 0xc9, 0xe8, 0x88, 0xb5, 0xfe, 0x96, 0xdd, 0x91, 0x61, 0xe3, 0xb6,
+	0xc0, 0x74, 0x8d, 0x64, 0x96, 0x06, 0x49, 0xc7, 0xad, 0x71, 0x5b, 0xe0, 0x7d, 0xcb, 0xd4, 0x7f,
+	0x08, 0x5c, 0x6e, 0x2b, 0xd4, 0xc0, 0xa2, 0x14, 0x93, 0xcf, 0x0e, 0x0c, 0xdb, 0x00, 0x5d, 0x65,
+	0x4b, 0xc7, 0xdf, 0xcb, 0xf2, 0x1c, 0x9d, 0x6b, 0x7d, 0x4a, 0x86, 0x7d, 0x61, 0x62, 0x73, 0x0c,
+	0xc0, 0x9a, 0x84, 0x2c, 0x31, 0xc6, 0x4c, 0x38, 0xe4, 0x31, 0x00, 0x7e, 0x54, 0x86, 0xd0, 0xa5,
+	0xa6, 0xe4, 0x10, 0x83, 0x79, 0xdc, 0x92, 0x59, 0xf9, 0x3b, 0x89, 0xde, 0x9e, 0x24, 0x7e, 0xdc,
+	0x06, 0x3b, 0x6e, 0xa7, 0xd7, 0x10, 0xde, 0x5c, 0x36, 0x3f, 0x5e, 0x5d, 0x40, 0x20, 0x8d, 0x07,
+	0xc9, 0xce, 0x3d, 0x24, 0xbb, 0xb7, 0x30, 0x1a, 0xed, 0x93, 0x64, 0xcb, 0xab, 0xe8, 0x21, 0x14,
+	0xfe, 0x18, 0xf2, 0x59, 0x9d, 0x7f, 0x0f, 0x00, 0xe4, 0x3c, 0x1c, 0xbb, 0x6d, 0x02, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// OAuth2Client is the client API for OAuth2 service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type OAuth2Client interface {
+	Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+}
+
+type oAuth2Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewOAuth2Client(cc *grpc.ClientConn) OAuth2Client {
+	return &oAuth2Client{cc}
+}
+
+func (c *oAuth2Client) Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/vuvo.oauth2.OAuth2/Token", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OAuth2Server is the server API for OAuth2 service.
+type OAuth2Server interface {
+	Token(context.Context, *TokenRequest) (*TokenResponse, error)
+}
+
+// UnimplementedOAuth2Server can be embedded to have forward compatible implementations.
+type UnimplementedOAuth2Server struct {
+}
+
+func (*UnimplementedOAuth2Server) Token(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Token not implemented")
+}
+
+func RegisterOAuth2Server(s *grpc.Server, srv OAuth2Server) {
+	s.RegisterService(&_OAuth2_serviceDesc, srv)
+}
+
+func _OAuth2_Token_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuth2Server).Token(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vuvo.oauth2.OAuth2/Token",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuth2Server).Token(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _OAuth2_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "vuvo.oauth2.OAuth2",
+	HandlerType: (*OAuth2Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Token",
+			Handler:    _OAuth2_Token_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "oauth2/oauth2.proto",
+}
@@ -0,0 +1,237 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clients map[string]Client
+
+func (c clients) VerifyClient(id, secret string) (Client, error) {
+	client, ok := c[id]
+	if !ok || (!client.Public && secret != "secret") {
+		return Client{}, errors.New("invalid client")
+	}
+	return client, nil
+}
+
+type users struct{}
+
+func (users) VerifyUser(username, password string) (string, error) {
+	if username != "alice" || password != "password" {
+		return "", errors.New("invalid user")
+	}
+	return "user-alice", nil
+}
+
+var (
+	server       *Server
+	accessTokens auth.Generator
+
+	mobile = Client{
+		ID:           "mobile",
+		Public:       true,
+		RedirectURIs: []string{"app://callback"},
+		GrantTypes:   []string{GrantPassword, GrantRefreshToken, GrantAuthorizationCode},
+	}
+)
+
+func TestMain(M *testing.M) {
+	redisAddress := "redis://localhost:6379"
+	if os.Getenv("USE_DOCKER_HOST") == "1" {
+		redisAddress = "redis://dockerhost:6379"
+	}
+	store := redis.NewWithPool(redisAddress)
+
+	accessTokens = auth.NewGenerator("oauth2-at", store)
+	var err error
+	server, err = NewServer(Config{
+		AccessTokens:  accessTokens,
+		RefreshTokens: auth.NewGenerator("oauth2-rt", store),
+		Codes:         auth.NewGenerator("oauth2-code", store),
+		Clients: clients{
+			"backend": {ID: "backend", GrantTypes: []string{GrantClientCredentials}},
+			"mobile":  mobile,
+		},
+		Users: users{},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(M.Run())
+}
+
+func TestPasswordAndRefreshToken(T *testing.T) {
+	resp, err := server.token(&TokenRequest{
+		GrantType: GrantPassword,
+		ClientId:  "mobile",
+		Username:  "alice",
+		Password:  "password",
+		Scope:     "profile",
+	})
+	require.Nil(T, err)
+	require.NotEmpty(T, resp.RefreshToken)
+
+	t, verr := accessTokens.Validate(resp.AccessToken)
+	require.NoError(T, verr)
+	assert.Equal(T, "user-alice", t.UserID)
+	clientID, scope := ParseValue(t)
+	assert.Equal(T, "mobile", clientID)
+	assert.Equal(T, "profile", scope)
+
+	T.Run("Rotate refresh token", func(t *testing.T) {
+		req := &TokenRequest{GrantType: GrantRefreshToken, ClientId: "mobile", RefreshToken: resp.RefreshToken}
+		next, err := server.token(req)
+		require.Nil(t, err)
+		assert.NotEqual(t, resp.RefreshToken, next.RefreshToken)
+
+		_, err = server.token(req)
+		assert.Equal(t, ErrInvalidGrant, err)
+	})
+
+	T.Run("Wrong password", func(t *testing.T) {
+		_, err := server.token(&TokenRequest{GrantType: GrantPassword, ClientId: "mobile", Username: "alice", Password: "x"})
+		require.NotNil(t, err)
+		assert.Equal(t, ErrInvalidGrant.Code, err.Code)
+	})
+}
+
+func TestClientCredentials(T *testing.T) {
+	T.Run("HTTP", func(t *testing.T) {
+		form := url.Values{"grant_type": {GrantClientCredentials}}
+		r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("backend", "secret")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp TokenResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.Empty(t, resp.RefreshToken)
+	})
+
+	T.Run("Invalid secret", func(t *testing.T) {
+		form := url.Values{"grant_type": {GrantClientCredentials}, "client_id": {"backend"}, "client_secret": {"x"}}
+		r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAuthorizationCodeWithPKCE(T *testing.T) {
+	verifier := auth.RandomToken(32)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	T.Run("Valid verifier", func(t *testing.T) {
+		code, err := server.IssueCode(mobile, "user-alice", "app://callback", "", challenge, ChallengeS256)
+		require.NoError(t, err)
+
+		req := &TokenRequest{
+			GrantType:    GrantAuthorizationCode,
+			ClientId:     "mobile",
+			Code:         code,
+			RedirectUri:  "app://callback",
+			CodeVerifier: verifier,
+		}
+		resp, terr := server.token(req)
+		require.Nil(t, terr)
+		assert.NotEmpty(t, resp.AccessToken)
+
+		// Codes are single use
+		_, terr = server.token(req)
+		assert.Equal(t, ErrInvalidGrant, terr)
+	})
+
+	T.Run("Invalid verifier", func(t *testing.T) {
+		code, err := server.IssueCode(mobile, "user-alice", "app://callback", "", challenge, ChallengeS256)
+		require.NoError(t, err)
+
+		_, terr := server.token(&TokenRequest{
+			GrantType:    GrantAuthorizationCode,
+			ClientId:     "mobile",
+			Code:         code,
+			RedirectUri:  "app://callback",
+			CodeVerifier: "wrong",
+		})
+		require.NotNil(t, terr)
+		assert.Equal(t, ErrInvalidGrant.Code, terr.Code)
+	})
+
+	T.Run("Concurrent exchange", func(t *testing.T) {
+		code, err := server.IssueCode(mobile, "user-alice", "app://callback", "", challenge, ChallengeS256)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var issued int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, terr := server.token(&TokenRequest{
+					GrantType:    GrantAuthorizationCode,
+					ClientId:     "mobile",
+					Code:         code,
+					RedirectUri:  "app://callback",
+					CodeVerifier: verifier,
+				})
+				if terr == nil {
+					atomic.AddInt32(&issued, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), issued)
+	})
+
+	T.Run("Unregistered redirect URI", func(t *testing.T) {
+		_, err := server.IssueCode(mobile, "user-alice", "https://evil.example/callback", "", challenge, ChallengeS256)
+		assert.Equal(t, ErrInvalidRequest.Code, err.(*Error).Code)
+	})
+}
+
+// plainGenerator hides the optional interfaces of a generator
+type plainGenerator struct {
+	auth.Generator
+}
+
+func TestNewServerInvalidConfig(t *testing.T) {
+	_, err := NewServer(Config{Clients: clients{}})
+	assert.Error(t, err)
+
+	_, err = NewServer(Config{
+		AccessTokens:  accessTokens,
+		RefreshTokens: accessTokens,
+		Codes:         accessTokens,
+	})
+	assert.Error(t, err)
+
+	_, err = NewServer(Config{
+		AccessTokens:  accessTokens,
+		RefreshTokens: plainGenerator{accessTokens},
+		Codes:         accessTokens,
+		Clients:       clients{},
+	})
+	assert.Error(t, err)
+}
@@ -28,16 +28,33 @@ type Store interface {
 	Del(keys ...string) error
 }
 
+// AtomicStore is implemented by stores supporting atomic operations and
+// Lua scripts, stores returned by New implement it
+type AtomicStore interface {
+	Store
+	Expire(k string, ttl int) error
+	IncrWithTTL(k string, ttl int) (int, error)
+	GetDel(k string) (string, error)
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Pinger is implemented by stores which can check their connection
+type Pinger interface {
+	Ping() error
+}
+
 type redisStore struct {
 	pool *redis.Pool
 }
 
-// New returns new Store
+// New returns new Store, it also implements io.Closer closing pool
 func New(pool *redis.Pool) Store {
 	return &redisStore{pool: pool}
 }
 
-// NewWithPool returns new Redis Store with default pool config
+// NewWithPool returns new Redis Store with default pool config. Connection
+// errors are returned by the store methods, so a health checker can report
+// an unreachable Redis.
 func NewWithPool(address string) Store {
 	redisPool := &redis.Pool{
 		MaxIdle:     50,
@@ -46,7 +63,7 @@ func NewWithPool(address string) Store {
 		Dial: func() (redis.Conn, error) {
 			c, err := redis.DialURL(address)
 			if err != nil {
-				ll.Fatal("Unable to connect to Redis", l.Error(err), l.String("endpoint", address))
+				ll.Error("Unable to connect to Redis", l.Error(err), l.String("endpoint", address))
 				return nil, err
 			}
 			return c, nil
@@ -56,10 +73,7 @@ func NewWithPool(address string) Store {
 				return nil
 			}
 			_, err := c.Do("PING")
-			if err != nil {
-				ll.Fatal("err", l.Error(err))
-			}
-			return nil
+			return err
 		},
 	}
 	return New(redisPool)
@@ -132,12 +146,35 @@ func (r redisStore) GetString(k string) (string, error) {
 	return s, err
 }
 
+// GetStrings returns keys matching given pattern. It uses SCAN, so redis
+// is not blocked on large databases.
 func (r redisStore) GetStrings(p string) ([]string, error) {
 	c := r.pool.Get()
 	defer c.Close()
 
-	values, err := redis.Strings(c.Do("KEYS", p))
-	return values, err
+	var values []string
+	seen := make(map[string]bool)
+	cursor := 0
+	for {
+		reply, err := redis.Values(c.Do("SCAN", cursor, "MATCH", p, "COUNT", 1000))
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return nil, err
+		}
+		// SCAN may return a key more than once
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				values = append(values, k)
+			}
+		}
+		if cursor == 0 {
+			return values, nil
+		}
+	}
 }
 
 func (r redisStore) SetUint64(k string, v uint64) error {
@@ -179,11 +216,85 @@ func (r redisStore) GetTTL(k string) (int, error) {
 	return result, err
 }
 
+// ttl: time in second
+func (r redisStore) Expire(k string, ttl int) error {
+	c := r.pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("EXPIRE", k, ttl)
+	return err
+}
+
+// incrWithTTLScript increases a counter and sets its TTL in one step, so a
+// counter never lives without TTL
+var incrWithTTLScript = redis.NewScript(1, `
+local n = redis.call("INCR", KEYS[1])
+if redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// IncrWithTTL increases the counter at given key atomically, the ttl is set
+// when the counter is created. ttl: time in second
+func (r redisStore) IncrWithTTL(k string, ttl int) (int, error) {
+	c := r.pool.Get()
+	defer c.Close()
+
+	return redis.Int(incrWithTTLScript.Do(c, k, ttl))
+}
+
+// GetDel returns value at given key and deletes it atomically
+func (r redisStore) GetDel(k string) (string, error) {
+	c := r.pool.Get()
+	defer c.Close()
+
+	c.Send("MULTI")
+	c.Send("GET", k)
+	c.Send("DEL", k)
+	values, err := redis.Values(c.Do("EXEC"))
+	if err != nil {
+		return "", err
+	}
+
+	s, err := redis.String(values[0], nil)
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return s, err
+}
+
+// Eval runs given Lua script atomically
+func (r redisStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	c := r.pool.Get()
+	defer c.Close()
+
+	ks := make([]interface{}, 0, len(keys)+len(args))
+	for _, k := range keys {
+		ks = append(ks, k)
+	}
+	ks = append(ks, args...)
+
+	return redis.NewScript(len(keys), script).Do(c, ks...)
+}
+
 func (r redisStore) IsExist(k string) bool {
 	s, _ := r.GetString(k)
 	return s != ""
 }
 
+func (r redisStore) Ping() error {
+	c := r.pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("PING")
+	return err
+}
+
+func (r redisStore) Close() error {
+	return r.pool.Close()
+}
+
 func (r redisStore) Del(keys ...string) error {
 	ks := make([]interface{}, len(keys))
 	for i := range keys {
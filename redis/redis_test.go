@@ -11,7 +11,7 @@ import (
 )
 
 var (
-	store Store
+	store AtomicStore
 	ll    = l.New()
 )
 
@@ -20,7 +20,7 @@ func init() {
 	if os.Getenv("USE_DOCKER_HOST") == "1" {
 		redisAddress = "redis://dockerhost:6379"
 	}
-	store = NewWithPool(redisAddress)
+	store = NewWithPool(redisAddress).(AtomicStore)
 }
 
 func TestGetSetInterface(T *testing.T) {
@@ -95,7 +95,8 @@ func TestGetStrings(t *testing.T) {
 
 	values, err := store.GetStrings("t:*")
 	REQUIRE.Nil(t, err)
-	REQUIRE.NotEmpty(t, values)
+	REQUIRE.Contains(t, values, "t:foo")
+	REQUIRE.Contains(t, values, "t:bar")
 }
 
 func TestGetSetUint64(T *testing.T) {
@@ -116,6 +117,60 @@ func TestGetSetUint64(T *testing.T) {
 	})
 }
 
+func TestIncrWithTTL(t *testing.T) {
+	defer store.Del("counter")
+
+	n, err := store.IncrWithTTL("counter", 10)
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, 1, n)
+
+	n, err = store.IncrWithTTL("counter", 10)
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, 2, n)
+
+	ttl, err := store.GetTTL("counter")
+	REQUIRE.NoError(t, err)
+	REQUIRE.True(t, ttl > 0 && ttl <= 10)
+
+	// A counter left without TTL gets one
+	REQUIRE.NoError(t, store.SetString("counter", "5"))
+	n, err = store.IncrWithTTL("counter", 10)
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, 6, n)
+	ttl, err = store.GetTTL("counter")
+	REQUIRE.NoError(t, err)
+	REQUIRE.True(t, ttl > 0 && ttl <= 10)
+}
+
+func TestGetDel(t *testing.T) {
+	err := store.SetString("getdel", "bar")
+	REQUIRE.NoError(t, err)
+
+	v, err := store.GetDel("getdel")
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, "bar", v)
+
+	v, err = store.GetDel("getdel")
+	REQUIRE.NoError(t, err)
+	REQUIRE.Empty(t, v)
+}
+
+func TestEval(t *testing.T) {
+	defer store.Del("eval")
+
+	v, err := store.Eval(`return redis.call("SET", KEYS[1], ARGV[1])`, []string{"eval"}, "bar")
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, "OK", v)
+
+	s, err := store.GetString("eval")
+	REQUIRE.NoError(t, err)
+	REQUIRE.Equal(t, "bar", s)
+}
+
+func TestPing(t *testing.T) {
+	REQUIRE.NoError(t, store.(Pinger).Ping())
+}
+
 func TestDel(t *testing.T) {
 	values, err := store.GetStrings("t:*")
 	REQUIRE.NoError(t, err)
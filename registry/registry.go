@@ -1,14 +1,26 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
 
 	consul "github.com/hashicorp/consul/api"
 	uuid "github.com/satori/go.uuid"
 )
 
+// Default values of Check
+const (
+	DefaultCheckTTL                = 10 * time.Second
+	DefaultDeregisterCriticalAfter = time.Minute
+)
+
+var ll = l.New()
+
 // NewClient returns a new Client with connection to consul
 func NewClient(addr string) (*Client, error) {
 	cfg := consul.DefaultConfig()
@@ -27,8 +39,66 @@ type Client struct {
 	*consul.Client
 }
 
+// Check configures the health check of a registered service. Either TTL
+// or GRPCInterval must be set.
+type Check struct {
+	// TTL enables a check which is passed by the service itself,
+	// see KeepAlive
+	TTL time.Duration
+
+	// GRPCInterval enables a check calling grpc.health.v1 of the service
+	// from the consul agent
+	GRPCInterval time.Duration
+	GRPCUseTLS   bool
+
+	// DeregisterCriticalAfter removes the service once its check is
+	// critical for the duration, e.g. after the process crashed
+	DeregisterCriticalAfter time.Duration
+}
+
+// RegisterOption allows optional config for Register
+type RegisterOption func(reg *consul.AgentServiceRegistration)
+
+// WithCheck registers the service with given health check
+func WithCheck(check Check) RegisterOption {
+	return func(reg *consul.AgentServiceRegistration) {
+		deregisterAfter := check.DeregisterCriticalAfter
+		if deregisterAfter <= 0 {
+			deregisterAfter = DefaultDeregisterCriticalAfter
+		}
+		c := &consul.AgentServiceCheck{
+			CheckID:                        CheckID(reg.ID),
+			DeregisterCriticalServiceAfter: deregisterAfter.String(),
+		}
+		if check.GRPCInterval > 0 {
+			c.GRPC = net.JoinHostPort(reg.Address, strconv.Itoa(reg.Port))
+			c.GRPCUseTLS = check.GRPCUseTLS
+			c.Interval = check.GRPCInterval.String()
+		} else {
+			ttl := check.TTL
+			if ttl <= 0 {
+				ttl = DefaultCheckTTL
+			}
+			c.TTL = ttl.String()
+		}
+		reg.Check = c
+	}
+}
+
+// WithTags registers the service with given tags
+func WithTags(tags ...string) RegisterOption {
+	return func(reg *consul.AgentServiceRegistration) {
+		reg.Tags = append(reg.Tags, tags...)
+	}
+}
+
+// CheckID returns ID of the check of given service registered WithCheck
+func CheckID(serviceID string) string {
+	return "service:" + serviceID
+}
+
 // Register a service with registry
-func (c *Client) Register(name string, port string) (string, error) {
+func (c *Client) Register(name string, port string, opts ...RegisterOption) (string, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
 		return "", fmt.Errorf("unable to determine local addr: %v", err)
@@ -47,10 +117,35 @@ func (c *Client) Register(name string, port string) (string, error) {
 		Port:    nPort,
 		Address: localAddr.IP.String(),
 	}
+	for _, fn := range opts {
+		fn(reg)
+	}
 
 	return uuid, c.Agent().ServiceRegister(reg)
 }
 
+// KeepAlive updates the TTL check of given service every interval until
+// ctx is done. The check fails while healthy returns an error.
+func (c *Client) KeepAlive(ctx context.Context, id string, interval time.Duration, healthy func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, output := consul.HealthPassing, ""
+		if err := healthy(); err != nil {
+			status, output = consul.HealthCritical, err.Error()
+		}
+		if err := c.Agent().UpdateTTL(CheckID(id), output, status); err != nil {
+			ll.Warn("Unable to update TTL check", l.String("id", id), l.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Deregister removes the service address from registry
 func (c *Client) Deregister(id string) error {
 	return c.Agent().ServiceDeregister(id)
@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent serves the consul agent endpoints used by Client
+type fakeAgent struct {
+	mu       sync.Mutex
+	services map[string]*consul.AgentServiceRegistration
+	updates  []string
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		reg := &consul.AgentServiceRegistration{}
+		if err := json.NewDecoder(r.Body).Decode(reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.services[reg.ID] = reg
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		var update struct{ Status, Output string }
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		checkID := strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/")
+		if a.service(checkID) == nil {
+			http.Error(w, "Unknown check", http.StatusNotFound)
+			return
+		}
+		a.updates = append(a.updates, strings.TrimSpace(update.Status+" "+update.Output))
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(a.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *fakeAgent) service(checkID string) *consul.AgentServiceRegistration {
+	for _, reg := range a.services {
+		if reg.Check != nil && reg.Check.CheckID == checkID {
+			return reg
+		}
+	}
+	return nil
+}
+
+func (a *fakeAgent) Updates() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.updates...)
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeAgent, func()) {
+	agent := &fakeAgent{services: make(map[string]*consul.AgentServiceRegistration)}
+	srv := httptest.NewServer(agent)
+	c, err := NewClient(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	return c, agent, srv.Close
+}
+
+func TestRegister(t *testing.T) {
+	c, agent, stop := newTestClient(t)
+	defer stop()
+
+	id, err := c.Register("foo", "8080", WithCheck(Check{TTL: time.Second}), WithTags("v1"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "foo-"))
+
+	reg := agent.services[id]
+	require.NotNil(t, reg)
+	assert.Equal(t, "foo", reg.Name)
+	assert.Equal(t, 8080, reg.Port)
+	assert.Equal(t, []string{"v1"}, reg.Tags)
+	require.NotNil(t, reg.Check)
+	assert.Equal(t, CheckID(id), reg.Check.CheckID)
+	assert.Equal(t, "1s", reg.Check.TTL)
+	assert.Equal(t, DefaultDeregisterCriticalAfter.String(), reg.Check.DeregisterCriticalServiceAfter)
+
+	id, err = c.Register("bar", "9090", WithCheck(Check{GRPCInterval: 5 * time.Second}))
+	require.NoError(t, err)
+	reg = agent.services[id]
+	assert.Equal(t, reg.Address+":9090", reg.Check.GRPC)
+	assert.Equal(t, "5s", reg.Check.Interval)
+	assert.Empty(t, reg.Check.TTL)
+
+	require.NoError(t, c.Deregister(id))
+	assert.Nil(t, agent.services[id])
+	assert.Len(t, agent.services, 1)
+}
+
+func TestKeepAlive(t *testing.T) {
+	c, agent, stop := newTestClient(t)
+	defer stop()
+
+	id, err := c.Register("foo", "8080", WithCheck(Check{TTL: time.Second}))
+	require.NoError(t, err)
+
+	var (
+		mu         sync.Mutex
+		healthyErr error
+	)
+	healthy := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return healthyErr
+	}
+	waitUpdate := func(update string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			updates := agent.Updates()
+			if len(updates) > 0 && updates[len(updates)-1] == update {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("%q not received, updates %v", update, agent.Updates())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.KeepAlive(ctx, id, 10*time.Millisecond, healthy)
+	}()
+
+	waitUpdate(consul.HealthPassing)
+	mu.Lock()
+	healthyErr = errors.New("redis: refused")
+	mu.Unlock()
+	waitUpdate(consul.HealthCritical + " redis: refused")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("KeepAlive did not return")
+	}
+	n := len(agent.Updates())
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, agent.Updates(), n, "no update after KeepAlive returned")
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// CatalogPath serves the method catalog as JSON on the admin server
+const CatalogPath = "/methods"
+
+// ReflectionMethods of gRPC server reflection. Add them to MethodExceptions
+// to let grpcurl list services without credentials.
+var ReflectionMethods = []string{
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Method describes a registered gRPC method
+type Method struct {
+	FullMethod      string `json:"full_method"`
+	Service         string `json:"service"`
+	Name            string `json:"name"`
+	ClientStreaming bool   `json:"client_streaming"`
+	ServerStreaming bool   `json:"server_streaming"`
+
+	// Public is true when the built-in auth interceptor lets the method
+	// through without credentials, i.e. it is in MethodExceptions or the
+	// interceptor is disabled
+	Public bool `json:"public"`
+}
+
+// Methods returns the catalog of registered methods sorted by FullMethod
+func (s *server) Methods() []Method {
+	var methods []Method
+	for service, info := range s.grpcServer.GetServiceInfo() {
+		for _, m := range info.Methods {
+			fullMethod := "/" + service + "/" + m.Name
+			methods = append(methods, Method{
+				FullMethod:      fullMethod,
+				Service:         service,
+				Name:            m.Name,
+				ClientStreaming: m.IsClientStream,
+				ServerStreaming: m.IsServerStream,
+				Public:          s.noAuth || contains(s.exceptions, fullMethod),
+			})
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].FullMethod < methods[j].FullMethod
+	})
+	return methods
+}
+
+func (s *server) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Methods()); err != nil {
+		ll.Error("Error writing catalog", l.Error(err))
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func find(methods []Method, fullMethod string) (Method, bool) {
+	for _, m := range methods {
+		if m.FullMethod == fullMethod {
+			return m, true
+		}
+	}
+	return Method{}, false
+}
+
+func TestMethods(t *testing.T) {
+	s, conn := newTestServer(t, Args{Reflection: true})
+	defer conn.Close()
+
+	methods := s.Methods()
+	check, ok := find(methods, "/grpc.health.v1.Health/Check")
+	require.True(t, ok)
+	assert.Equal(t, Method{
+		FullMethod: "/grpc.health.v1.Health/Check",
+		Service:    "grpc.health.v1.Health",
+		Name:       "Check",
+		Public:     true,
+	}, check)
+
+	watch, _ := find(methods, "/grpc.health.v1.Health/Watch")
+	assert.True(t, watch.ServerStreaming)
+
+	reflect, ok := find(methods, ReflectionMethods[0])
+	require.True(t, ok)
+	assert.False(t, reflect.Public)
+	assert.True(t, reflect.ClientStreaming && reflect.ServerStreaming)
+
+	s, conn = newTestServer(t, Args{})
+	defer conn.Close()
+	_, ok = find(s.Methods(), ReflectionMethods[0])
+	assert.False(t, ok)
+}
+
+func TestReflection(t *testing.T) {
+	s, conn := newTestServer(t, Args{Reflection: true, MethodExceptions: ReflectionMethods})
+	errCh := start(s)
+	defer func() {
+		conn.Close()
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, wait(t, errCh))
+	}()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background(), grpc.WaitForReady(true))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().Service {
+		services = append(services, service.Name)
+	}
+	assert.Contains(t, services, "grpc.health.v1.Health")
+	assert.Contains(t, services, "grpc.reflection.v1alpha.ServerReflection")
+}
+
+func TestCatalogHTTP(t *testing.T) {
+	// bufconn does not support the deadlines used by HTTP/1 servers
+	adminLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, conn := newTestServer(t, Args{AdminListener: adminLis})
+	defer conn.Close()
+	errCh := start(s)
+	defer func() {
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, wait(t, errCh))
+	}()
+
+	resp, err := http.Get("http://" + adminLis.Addr().String() + CatalogPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var methods []Method
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&methods))
+	assert.Equal(t, s.Methods(), methods)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/admin"
+	"github.com/go-xtek/vuvo-go/gateway"
+	grpcTransport "github.com/go-xtek/vuvo-go/grpc"
+	"github.com/go-xtek/vuvo-go/l"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// GatewayPrefix is the path prefix of JSON transcoded gRPC methods, e.g.
+// POST /api/pkg.Service/Method
+const GatewayPrefix = "/api"
+
+// Timeouts of HTTP servers. Requests have no read or write timeout, they
+// are bounded by gRPC deadlines and gateway.MaxRequestSize.
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpIdleTimeout       = 2 * time.Minute
+)
+
+// httpServer serves the JSON gateway, and gRPC when running on a single
+// port, or admin endpoints
+type httpServer struct {
+	*http.Server
+	gatewayConn *grpc.ClientConn
+	grpc        *grpcHandler
+}
+
+// shutdown stops accepting requests and waits for active ones, it must be
+// called before grpc.Server.GracefulStop
+func (h *httpServer) shutdown(ctx context.Context) {
+	if h.grpc != nil {
+		h.grpc.startDraining()
+	}
+	if err := h.Server.Shutdown(ctx); err != nil {
+		ll.Error("HTTP Server Error", l.Error(err))
+	}
+	if h.grpc != nil {
+		h.grpc.wait()
+	}
+	if h.gatewayConn != nil {
+		h.gatewayConn.Close()
+	}
+}
+
+// grpcHandler serves gRPC requests received by the HTTP server. They are
+// tracked here, as grpc.Server.GracefulStop can not drain them.
+type grpcHandler struct {
+	grpcServer *grpc.Server
+
+	mu       sync.Mutex
+	draining bool
+	active   sync.WaitGroup
+}
+
+func (h *grpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if h.draining {
+		h.mu.Unlock()
+		// Trailers-only response, clients retry on another server
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "14") // Unavailable
+		w.Header().Set("Grpc-Message", "Server is shutting down")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	h.active.Add(1)
+	h.mu.Unlock()
+
+	defer h.active.Done()
+	h.grpcServer.ServeHTTP(w, r)
+}
+
+func (h *grpcHandler) startDraining() {
+	h.mu.Lock()
+	h.draining = true
+	h.mu.Unlock()
+}
+
+func (h *grpcHandler) wait() {
+	h.active.Wait()
+}
+
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// newHTTPServer returns the HTTP server. The gateway calls the gRPC server
+// through an in-process connection, so requests pass the same interceptors
+// as gRPC clients.
+func (s *server) newHTTPServer(singlePort bool) (*httpServer, error) {
+	// The connection never leaves the process, it is served without TLS and
+	// has no peer identity, see grpcTransport.ServerCredentials
+	bufLis := bufconn.Listen(1 << 20)
+	go func() {
+		if err := s.grpcServer.Serve(grpcTransport.InProcessListener(bufLis)); err != nil && err != grpc.ErrServerStopped {
+			ll.Error("GRPC Server Error", l.Error(err))
+		}
+	}()
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return bufLis.Dial()
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	gw := gateway.New(conn, s.grpcServer.GetServiceInfo())
+	mux.Handle(GatewayPrefix+"/", http.StripPrefix(GatewayPrefix, gw))
+	for pattern, handler := range s.httpHandlers {
+		mux.Handle(pattern, handler)
+	}
+
+	h := &httpServer{
+		Server:      newNetHTTPServer(mux),
+		gatewayConn: conn,
+	}
+	if singlePort {
+		h.grpc = &grpcHandler{grpcServer: s.grpcServer}
+		h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isGRPC(r) {
+				h.grpc.ServeHTTP(w, r)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})
+		if s.tlsConfig != nil {
+			h.TLSConfig = s.tlsConfig.Clone()
+		} else {
+			// gRPC clients without TLS speak HTTP/2 with prior knowledge
+			h.Handler = h2c.NewHandler(h.Handler, &http2.Server{})
+		}
+	}
+	return h, nil
+}
+
+// newAdminServer returns the HTTP server of admin endpoints and the method
+// catalog
+func (s *server) newAdminServer() *httpServer {
+	mux := admin.NewMux(admin.Config{
+		Name:    s.name,
+		Version: s.version,
+		Health:  s.healthServer,
+	})
+	mux.HandleFunc(CatalogPath, s.serveCatalog)
+	return &httpServer{Server: newNetHTTPServer(mux)}
+}
+
+// newNetHTTPServer returns an http.Server of handler with the server timeouts
+func newNetHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+}
+
+// serve serves h on lis until it is shut down
+func (s *server) serve(h *httpServer, lis net.Listener, fail func(error)) {
+	var err error
+	if h.TLSConfig != nil {
+		err = h.ServeTLS(lis, "", "")
+	} else {
+		err = h.Serve(lis)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		ll.Error("HTTP Server Error", l.Error(err))
+		fail(err)
+	}
+}
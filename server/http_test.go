@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/certs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGatewayHasNoPeerIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(t, dir)
+
+	var (
+		mu        sync.Mutex
+		providers []bool
+	)
+	lis := bufconn.Listen(1 << 20)
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, err := NewServer(Args{
+		Listener:            lis,
+		HTTPListener:        httpLis,
+		DisableInterceptors: []string{InterceptorTracing},
+		TLS:                 &certs.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile},
+		PeerRules:           auth.PeerRules{{Pattern: "localhost", Provider: auth.ServiceProviderClaim{ID: "self"}}},
+		UnaryInterceptorsAfter: []grpc.UnaryServerInterceptor{
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				_, ok := auth.ProviderFromContext(ctx)
+				mu.Lock()
+				providers = append(providers, ok)
+				mu.Unlock()
+				return handler(ctx, req)
+			},
+		},
+	})
+	require.NoError(t, err)
+	errCh := start(s)
+	defer func() {
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, wait(t, errCh))
+	}()
+
+	// gRPC clients presenting the certificate are authenticated by PeerRules
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(mustParse(t, cert.Certificate[0]))
+	conn, err := grpc.Dial("localhost",
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+		})),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+
+	// Anonymous gateway requests are not
+	resp, err := http.Post("http://"+httpLis.Addr().String()+GatewayPrefix+"/grpc.health.v1.Health/Check", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{true, false}, providers)
+}
+
+func TestAdminServer(t *testing.T) {
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	adminLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, conn := newTestServer(t, Args{HTTPListener: httpLis, AdminListener: adminLis})
+	defer conn.Close()
+	errCh := start(s)
+	defer func() {
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, wait(t, errCh))
+	}()
+
+	get := func(lis net.Listener, path string) int {
+		resp, err := http.Get("http://" + lis.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Admin endpoints are never served with the gateway
+	assert.Equal(t, http.StatusNotFound, get(httpLis, "/debug/pprof/cmdline"))
+	assert.Equal(t, http.StatusNotFound, get(httpLis, "/debug/log"))
+	assert.Equal(t, http.StatusNotFound, get(httpLis, CatalogPath))
+
+	assert.Equal(t, http.StatusOK, get(adminLis, "/debug/pprof/cmdline"))
+	assert.Equal(t, http.StatusOK, get(adminLis, CatalogPath))
+	assert.Equal(t, http.StatusOK, get(adminLis, "/healthz"))
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// DefaultStopTimeout is used when a hook or runnable has no timeout
+const DefaultStopTimeout = 5 * time.Second
+
+// Hook is a component started before the server accepts requests and
+// stopped after it finished serving, in reverse order
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+
+	// Timeout of each of OnStart and OnStop, DefaultStopTimeout when 0
+	Timeout time.Duration
+}
+
+// Runnable is a long-running component, e.g. a queue worker or a redis
+// subscriber. Run must return when ctx is cancelled, an error stops
+// the server.
+type Runnable interface {
+	Run(ctx context.Context) error
+}
+
+// RunnableFunc adapts a function to Runnable
+type RunnableFunc func(ctx context.Context) error
+
+// Run implements Runnable
+func (f RunnableFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+type runnable struct {
+	name    string
+	r       Runnable
+	timeout time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type lifecycle struct {
+	mu        sync.Mutex
+	hooks     []Hook
+	runnables []*runnable
+
+	started []Hook
+}
+
+func (lc *lifecycle) addHook(h Hook) {
+	if h.Timeout <= 0 {
+		h.Timeout = DefaultStopTimeout
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.hooks = append(lc.hooks, h)
+}
+
+func (lc *lifecycle) addRunnable(name string, r Runnable, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.runnables = append(lc.runnables, &runnable{
+		name:    name,
+		r:       r,
+		timeout: timeout,
+	})
+}
+
+// start calls OnStart of hooks in order, then starts runnables. Hooks
+// started before a failing one are stopped. A runnable returning an error
+// calls fail.
+func (lc *lifecycle) start(fail func(error)) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for _, h := range lc.hooks {
+		if h.OnStart != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+			err := h.OnStart(ctx)
+			cancel()
+			if err != nil {
+				ll.Error("Error starting component", l.String("name", h.Name), l.Error(err))
+				lc.stopHooks()
+				return err
+			}
+		}
+		lc.started = append(lc.started, h)
+	}
+
+	for _, r := range lc.runnables {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.cancel = cancel
+		r.done = make(chan struct{})
+		go func(r *runnable, ctx context.Context) {
+			defer close(r.done)
+			err := r.r.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				ll.Error("Component failed, stopping server", l.String("name", r.name), l.Error(err))
+				fail(fmt.Errorf("%v: %v", r.name, err))
+			}
+		}(r, ctx)
+	}
+	return nil
+}
+
+// stop cancels runnables, then calls OnStop of started hooks, both in
+// reverse order. Components not stopping within their timeout are left
+// behind.
+func (lc *lifecycle) stop() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for i := len(lc.runnables) - 1; i >= 0; i-- {
+		r := lc.runnables[i]
+		if r.cancel == nil {
+			continue
+		}
+		r.cancel()
+		select {
+		case <-r.done:
+		case <-time.After(r.timeout):
+			ll.Error("Component did not stop in time", l.String("name", r.name), l.Duration("timeout", r.timeout))
+		}
+	}
+	lc.stopHooks()
+}
+
+func (lc *lifecycle) stopHooks() {
+	for i := len(lc.started) - 1; i >= 0; i-- {
+		h := lc.started[i]
+		if h.OnStop == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+		if err := h.OnStop(ctx); err != nil {
+			ll.Error("Error stopping component", l.String("name", h.Name), l.Error(err))
+		}
+		cancel()
+	}
+	lc.started = nil
+}
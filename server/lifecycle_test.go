@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) hook(name string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			r.add("start " + name)
+			return startErr
+		},
+		OnStop: func(ctx context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func (r *recorder) runnable(name string) Runnable {
+	return RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		r.add("cancel " + name)
+		return ctx.Err()
+	})
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var r recorder
+	var lc lifecycle
+	lc.addHook(r.hook("db", nil))
+	lc.addHook(r.hook("cache", nil))
+	lc.addRunnable("worker", r.runnable("worker"), 0)
+	lc.addRunnable("cron", r.runnable("cron"), 0)
+
+	assert.NoError(t, lc.start(func(err error) { t.Error("unexpected failure", err) }))
+	lc.stop()
+	assert.Equal(t, []string{
+		"start db", "start cache",
+		"cancel cron", "cancel worker",
+		"stop cache", "stop db",
+	}, r.events)
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	var r recorder
+	var lc lifecycle
+	lc.addHook(r.hook("db", nil))
+	lc.addHook(r.hook("cache", errors.New("refused")))
+	lc.addHook(r.hook("other", nil))
+
+	assert.Error(t, lc.start(func(error) {}))
+	assert.Equal(t, []string{"start db", "start cache", "stop db"}, r.events)
+}
+
+func TestLifecycleRunnableError(t *testing.T) {
+	var lc lifecycle
+	lc.addRunnable("worker", RunnableFunc(func(ctx context.Context) error {
+		return errors.New("connection lost")
+	}), 0)
+
+	failed := make(chan struct{})
+	assert.NoError(t, lc.start(func(error) { close(failed) }))
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("failure is not propagated")
+	}
+	lc.stop()
+}
+
+func TestLifecycleStopTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	var lc lifecycle
+	lc.addRunnable("stuck", RunnableFunc(func(ctx context.Context) error {
+		<-block
+		return nil
+	}), 10*time.Millisecond)
+
+	assert.NoError(t, lc.start(func(error) {}))
+	start := time.Now()
+	lc.stop()
+	assert.True(t, time.Since(start) < time.Second)
+}
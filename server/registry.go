@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/go-xtek/vuvo-go/l"
+	"github.com/go-xtek/vuvo-go/registry"
+)
+
+// register registers the server with its health check and keeps a TTL
+// check alive. It returns a function deregistering the server.
+func (s *server) register() (func(), error) {
+	port := s.port
+	if addr, ok := s.Addr().(*net.TCPAddr); ok {
+		port = strconv.Itoa(addr.Port)
+	}
+	id, err := s.registry.Register(s.name, port, registry.WithCheck(s.registryCheck))
+	if err != nil {
+		return nil, err
+	}
+	ll.Info("Registered service", l.String("id", id))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	if s.registryCheck.GRPCInterval <= 0 {
+		ttl := s.registryCheck.TTL
+		if ttl <= 0 {
+			ttl = registry.DefaultCheckTTL
+		}
+		go func() {
+			defer close(done)
+			s.registry.KeepAlive(ctx, id, ttl/2, s.healthErr)
+		}()
+	} else {
+		close(done)
+	}
+
+	return func() {
+		// Wait for KeepAlive, so no TTL update is sent after deregistering
+		cancel()
+		<-done
+		if err := s.registry.Deregister(id); err != nil {
+			ll.Error("Error deregistering service", l.String("id", id), l.Error(err))
+			return
+		}
+		ll.Info("Deregistered service", l.String("id", id))
+	}, nil
+}
+
+// healthErr returns the reason the server is not serving
+func (s *server) healthErr() error {
+	if s.healthServer.Serving() {
+		return nil
+	}
+	for name, err := range s.healthServer.Results() {
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	return errors.New("Not serving")
+}
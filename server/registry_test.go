@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent records calls to the consul agent
+type fakeAgent struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		a.calls = append(a.calls, "register")
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		a.calls = append(a.calls, "update")
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		a.calls = append(a.calls, "deregister")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *fakeAgent) Calls() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.calls...)
+}
+
+func TestServerRegistry(t *testing.T) {
+	agent := &fakeAgent{}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+	client, err := registry.NewClient(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	s, conn := newTestServer(t, Args{
+		Registry:      client,
+		RegistryCheck: registry.Check{TTL: 20 * time.Millisecond},
+	})
+	defer conn.Close()
+	errCh := start(s)
+
+	// The TTL check is kept alive until shutdown
+	deadline := time.Now().Add(5 * time.Second)
+	for len(agent.Calls()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	calls := agent.Calls()
+	require.True(t, len(calls) >= 3, "calls %v", calls)
+	assert.Equal(t, []string{"register", "update", "update"}, calls[:3])
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.NoError(t, wait(t, errCh))
+
+	// No update is sent after deregistering
+	time.Sleep(30 * time.Millisecond)
+	calls = agent.Calls()
+	assert.Equal(t, "deregister", calls[len(calls)-1])
+	assert.Len(t, calls, strings.Count(strings.Join(calls, " "), "update")+2)
+}
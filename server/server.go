@@ -2,31 +2,45 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
-	"os"
-	"os/signal"
-	"syscall"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 
 	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/certs"
 	grpcTransport "github.com/go-xtek/vuvo-go/grpc"
+	"github.com/go-xtek/vuvo-go/health"
 	"github.com/go-xtek/vuvo-go/l"
 	"github.com/go-xtek/vuvo-go/redis"
+	"github.com/go-xtek/vuvo-go/registry"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
+var ll = l.New()
+
+// DefaultShutdownTimeout is used when Args.ShutdownTimeout is 0
+const DefaultShutdownTimeout = 15 * time.Second
+
 var (
-	ll        = l.New()
-	ctx       context.Context
-	ctxCancel context.CancelFunc
+	// ErrServerStarted returns an error indicate that
+	// Start is called more than once
+	ErrServerStarted = errors.New("Server already started")
+
+	// ErrShutdownTimeout returns an error indicate that
+	// requests did not finish within the shutdown timeout
+	ErrShutdownTimeout = errors.New("Shutdown timeout")
 )
 
 // RegisterHandler ...
@@ -34,8 +48,48 @@ type RegisterHandler func(*grpc.Server) error
 
 // Server ...
 type Server interface {
-	Start()
+	// Start serves until ctx is done, Stop is called or a component
+	// fails, then shuts down gracefully. It returns the error stopping
+	// the server, nil when shutdown was requested.
+	Start(ctx context.Context) error
+
+	// Stop begins shutdown and waits until Start returns or ctx is done
+	Stop(ctx context.Context) error
+
+	// Addr returns the address gRPC is served on, nil until started
+	Addr() net.Addr
+
+	// Methods returns the catalog of registered methods, it is also
+	// served on CatalogPath by the admin server
+	Methods() []Method
+
 	RegisterServer(fn RegisterHandler) error
+
+	// AddHook registers a component started and stopped with the server
+	AddHook(h Hook)
+
+	// AddRunnable registers a long-running component, it is cancelled on
+	// shutdown and waited for at most timeout
+	AddRunnable(name string, r Runnable, timeout time.Duration)
+}
+
+// Names of built-in interceptors, in the order they are chained
+const (
+	InterceptorLog     = "log"
+	InterceptorTags    = "tags"
+	InterceptorTracing = "tracing"
+	InterceptorPeer    = "peer"
+	InterceptorAPIKey  = "apikey"
+	InterceptorAuth    = "auth"
+)
+
+var builtinInterceptors = []string{
+	InterceptorLog,
+	InterceptorTags,
+	InterceptorTracing,
+	InterceptorPeer,
+	InterceptorAPIKey,
+	InterceptorAuth,
 }
 
 // Args ...
@@ -44,50 +98,267 @@ type Args struct {
 	Host string
 	Port string
 
+	// GRPCOption is applied to the gRPC server. NewServer returns an error
+	// if it contains grpc.UnaryInterceptor or grpc.StreamInterceptor, use
+	// the interceptor args instead.
 	GRPCOption []grpc.ServerOption
 
+	// Interceptors chained before or after built-in interceptors, in
+	// given order
+	UnaryInterceptorsBefore  []grpc.UnaryServerInterceptor
+	UnaryInterceptorsAfter   []grpc.UnaryServerInterceptor
+	StreamInterceptorsBefore []grpc.StreamServerInterceptor
+	StreamInterceptorsAfter  []grpc.StreamServerInterceptor
+
+	// DisableInterceptors lists names of built-in interceptors
+	// which are not installed, e.g. InterceptorAuth
+	DisableInterceptors []string
+
+	// TLSConfig enables TLS. Set ClientAuth and ClientCAs to authenticate
+	// peers by their client certificates with PeerRules.
+	TLSConfig *tls.Config
+	PeerRules auth.PeerRules
+
+	// TLS loads the certificate and client CAs from files and reloads
+	// them when they are rotated. It can not be used with TLSConfig.
+	TLS *certs.Config
+
 	JaegerAddress string
 
 	Tracer           opentracing.Tracer
 	RedisStore       redis.Store
 	TokenGenerator   auth.Generator
+	TokenValidator   auth.Validator // Overrides TokenGenerator for validation, e.g. a chain validator
+	APIKeyValidator  auth.APIKeyValidator
+	Throttler        auth.Throttler
 	MethodExceptions []string
+
+	// Reflection enables gRPC server reflection, e.g. for grpcurl. It
+	// requires credentials unless ReflectionMethods are in
+	// MethodExceptions.
+	Reflection bool
+
+	// HealthCheckers drive the status of grpc.health.v1, a redis checker
+	// is added when RedisStore is set
+	HealthCheckers map[string]health.Checker
+	HealthInterval time.Duration
+
+	// HTTPPort enables an HTTP server with JSON transcoding of gRPC methods
+	// under GatewayPrefix and HTTPHandlers
+	HTTPPort string
+
+	// AdminPort enables an HTTP server with admin endpoints, see
+	// admin.NewMux, and the method catalog on CatalogPath. They include
+	// profiles, log levels and every registered method, so AdminPort
+	// must not be reachable from outside.
+	AdminPort string
+
+	// SinglePort serves the HTTP endpoints and gRPC on Port, gRPC requests
+	// are told apart by their content type. Without TLSConfig, gRPC is
+	// served with h2c.
+	SinglePort bool
+
+	HTTPHandlers map[string]http.Handler // Additional handlers by pattern
+	Version      string
+
+	// Registry registers the server once it is started and deregisters it
+	// as soon as shutdown begins. The check is a TTL check kept alive with
+	// the health status unless RegistryCheck configures a gRPC check.
+	Registry      *registry.Client
+	RegistryCheck registry.Check
+
+	// Listener is served instead of listening on Host:Port, e.g. a
+	// bufconn listener in tests. HTTPListener and AdminListener replace
+	// HTTPPort and AdminPort likewise.
+	Listener      net.Listener
+	HTTPListener  net.Listener
+	AdminListener net.Listener
+
+	// ShutdownTimeout bounds the graceful shutdown, requests still active
+	// are then cancelled. DefaultShutdownTimeout when 0.
+	ShutdownTimeout time.Duration
 }
 
 func (a *Args) validate() error {
-	if a.Port == "" {
+	if a.Port == "" && a.Listener == nil {
 		return errors.New("Arg port required")
 	}
-	if a.Tracer == nil {
+	if a.Tracer == nil && !contains(a.DisableInterceptors, InterceptorTracing) {
 		return errors.New("Tracer must be initial")
 	}
+	if a.TLS != nil && a.TLSConfig != nil {
+		return errors.New("TLS can not be used with TLSConfig")
+	}
+	mutualTLS := (a.TLSConfig != nil && a.TLSConfig.ClientCAs != nil) || (a.TLS != nil && a.TLS.ClientCAFile != "")
+	if len(a.PeerRules) > 0 && !mutualTLS {
+		return errors.New("PeerRules require TLSConfig with ClientCAs")
+	}
+	if a.SinglePort && (a.HTTPPort != "" || a.HTTPListener != nil) {
+		return errors.New("HTTPPort can not be used with SinglePort")
+	}
+	if a.AdminPort != "" && (a.AdminPort == a.Port || a.AdminPort == a.HTTPPort) {
+		return errors.New("AdminPort must differ from Port and HTTPPort")
+	}
+	for _, name := range a.DisableInterceptors {
+		if !contains(builtinInterceptors, name) {
+			return fmt.Errorf("Unknown interceptor %v", name)
+		}
+	}
 
 	return nil
 }
 
 // NewServer ...
-func NewServer(args Args) Server {
+func NewServer(args Args) (Server, error) {
 	if err := args.validate(); err != nil {
-		ll.Fatal("Args server invaild", l.Error(err))
-	}
-
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			grpcTransport.LogUnaryServerInterceptor(ll),
-			grpc_ctxtags.UnaryServerInterceptor(),
-			grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithTracer(args.Tracer)),
-			grpcTransport.AuthUnaryServerInterceptor(
-				grpcTransport.Authentication(args.TokenGenerator, "", args.MethodExceptions),
-			),
-		)),
-	)
+		return nil, err
+	}
+
+	tlsConfig := args.TLSConfig
+	var certReloader *certs.Reloader
+	if args.TLS != nil {
+		var err error
+		certReloader, err = certs.NewReloader(*args.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = certReloader.TLSConfig()
+	}
+
+	unary, stream := args.interceptors()
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unary...)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(stream...)),
+	}
+	// The JSON gateway connects in-process without TLS and peer identity
+	var tlsCreds credentials.TransportCredentials
+	if tlsConfig != nil {
+		tlsCreds = credentials.NewTLS(tlsConfig)
+	}
+	opts = append(opts, grpc.Creds(grpcTransport.ServerCredentials(tlsCreds)))
+	opts = append(opts, args.GRPCOption...)
+	grpcServer, err := newGRPCServer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	checkers := make(map[string]health.Checker)
+	if args.RedisStore != nil {
+		checkers["redis"] = health.Redis(args.RedisStore)
+	}
+	for name, checker := range args.HealthCheckers {
+		checkers[name] = checker
+	}
+	healthServer := health.NewServer(health.Config{
+		Checkers: checkers,
+		Interval: args.HealthInterval,
+	})
+	_ = healthServer.Register(grpcServer)
+	if args.Reflection {
+		reflection.Register(grpcServer)
+	}
 
+	shutdownTimeout := args.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
 	return &server{
-		args.Name,
-		args.Host,
-		args.Port,
-		grpcServer,
+		name:            args.Name,
+		host:            args.Host,
+		port:            args.Port,
+		httpPort:        args.HTTPPort,
+		adminPort:       args.AdminPort,
+		singlePort:      args.SinglePort,
+		httpHandlers:    args.HTTPHandlers,
+		version:         args.Version,
+		exceptions:      args.exceptions(),
+		noAuth:          contains(args.DisableInterceptors, InterceptorAuth),
+		tlsConfig:       tlsConfig,
+		certReloader:    certReloader,
+		registry:        args.Registry,
+		registryCheck:   args.RegistryCheck,
+		listener:        args.Listener,
+		httpListener:    args.HTTPListener,
+		adminListener:   args.AdminListener,
+		shutdownTimeout: shutdownTimeout,
+		grpcServer:      grpcServer,
+		healthServer:    healthServer,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}, nil
+}
+
+// newGRPCServer returns an error instead of the panic of grpc.NewServer when
+// an option is set twice, e.g. an interceptor in Args.GRPCOption
+func newGRPCServer(opts []grpc.ServerOption) (_ *grpc.Server, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("Invalid GRPCOption: %v", e)
+		}
+	}()
+	return grpc.NewServer(opts...), nil
+}
+
+// interceptors returns the unary and stream interceptor chains
+func (a *Args) interceptors() ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	var validator auth.Validator = a.TokenGenerator
+	if a.TokenValidator != nil {
+		validator = a.TokenValidator
+	}
+	var authOpts []grpcTransport.AuthOption
+	if a.Throttler != nil {
+		authOpts = append(authOpts, grpcTransport.WithThrottler(a.Throttler))
+	}
+	authFunc := grpcTransport.Authentication(validator, "", a.exceptions(), authOpts...)
+
+	unary := append([]grpc.UnaryServerInterceptor{}, a.UnaryInterceptorsBefore...)
+	stream := append([]grpc.StreamServerInterceptor{}, a.StreamInterceptorsBefore...)
+	for _, name := range builtinInterceptors {
+		if contains(a.DisableInterceptors, name) {
+			continue
+		}
+		switch name {
+		case InterceptorLog:
+			unary = append(unary, grpcTransport.LogUnaryServerInterceptor(ll))
+			stream = append(stream, grpcTransport.LogStreamServerInterceptor(ll))
+		case InterceptorTags:
+			unary = append(unary, grpc_ctxtags.UnaryServerInterceptor())
+			stream = append(stream, grpc_ctxtags.StreamServerInterceptor())
+		case InterceptorTracing:
+			unary = append(unary, grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithTracer(a.Tracer)))
+			stream = append(stream, grpc_opentracing.StreamServerInterceptor(grpc_opentracing.WithTracer(a.Tracer)))
+		case InterceptorPeer:
+			if len(a.PeerRules) > 0 {
+				unary = append(unary, grpcTransport.PeerUnaryServerInterceptor(a.PeerRules))
+				stream = append(stream, grpcTransport.PeerStreamServerInterceptor(a.PeerRules))
+			}
+		case InterceptorAPIKey:
+			if a.APIKeyValidator != nil {
+				unary = append(unary, grpcTransport.APIKeyUnaryServerInterceptor(a.APIKeyValidator))
+				stream = append(stream, grpcTransport.APIKeyStreamServerInterceptor(a.APIKeyValidator))
+			}
+		case InterceptorAuth:
+			unary = append(unary, grpcTransport.AuthUnaryServerInterceptor(authFunc))
+			stream = append(stream, grpcTransport.AuthStreamServerInterceptor(authFunc))
+		}
+	}
+	unary = append(unary, a.UnaryInterceptorsAfter...)
+	stream = append(stream, a.StreamInterceptorsAfter...)
+	return unary, stream
+}
+
+// exceptions returns methods called without credentials
+func (a *Args) exceptions() []string {
+	return append(append([]string{}, a.MethodExceptions...), health.Methods...)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
 }
 
 // Server ...
@@ -97,56 +368,235 @@ type server struct {
 	host string
 	port string
 
-	grpcServer *grpc.Server
+	httpPort     string
+	adminPort    string
+	singlePort   bool
+	httpHandlers map[string]http.Handler
+	version      string
+	tlsConfig    *tls.Config
+	certReloader *certs.Reloader
+
+	exceptions []string
+	noAuth     bool
+
+	registry      *registry.Client
+	registryCheck registry.Check
+
+	listener        net.Listener
+	httpListener    net.Listener
+	adminListener   net.Listener
+	shutdownTimeout time.Duration
+
+	grpcServer   *grpc.Server
+	healthServer *health.Server
+	lifecycle    lifecycle
+
+	mu       sync.Mutex
+	started  bool
+	addr     net.Addr
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
 }
 
-// Listen ...
-func (s server) listen() string {
-	return fmt.Sprintf("%s:%s", s.host, s.port)
+// listen returns lis when it is set, otherwise listens on host:port
+func (s *server) listen(lis net.Listener, port string) (net.Listener, error) {
+	if lis != nil {
+		return lis, nil
+	}
+	return net.Listen("tcp", net.JoinHostPort(s.host, port))
 }
 
 // Start ...
-func (s server) Start() {
-	ctx, ctxCancel = context.WithCancel(context.Background())
-	// Listen signal Ctrl + C
-	go func() {
-		defer ctxCancel()
+func (s *server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return ErrServerStarted
+	}
+	s.started = true
+	s.mu.Unlock()
+	defer close(s.done)
 
-		osSignal := make(chan os.Signal, 1)
-		signal.Notify(osSignal, syscall.SIGINT, syscall.SIGTERM)
-		ll.Info("Received OS signal", l.Stringer("signal", <-osSignal))
-	}()
+	var (
+		failOnce sync.Once
+		failErr  error
+		failed   = make(chan struct{})
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failErr = err
+			close(failed)
+		})
+	}
+
+	if err := s.lifecycle.start(fail); err != nil {
+		return err
+	}
 
-	lis, err := net.Listen("tcp", s.listen())
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.healthServer.Run(runCtx)
+	if s.certReloader != nil {
+		go s.certReloader.Run(runCtx)
+	}
+
+	httpSrvs, deregister, err := s.run(fail)
 	if err != nil {
-		ll.Fatal("Error start", l.Error(err))
+		fail(err)
 	}
-	ll.Info(s.name+" - GRPC Server started", l.String("listen", s.listen()))
 
-	go func() {
-		defer ctxCancel()
-		err := s.grpcServer.Serve(lis)
+	// Wait for shutdown request or any error from services
+	select {
+	case <-ctx.Done():
+	case <-s.stop:
+	case <-failed:
+	}
+	err = s.shutdown(httpSrvs, deregister)
+
+	select {
+	case <-failed:
+		return failErr
+	default:
+		return err
+	}
+}
+
+// run starts serving and registers the server. Components started before
+// an error are returned, so they are stopped by shutdown.
+func (s *server) run(fail func(error)) (httpSrvs []*httpServer, deregister func(), err error) {
+	lis, err := s.listen(s.listener, s.port)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	s.addr = lis.Addr()
+	s.mu.Unlock()
+
+	var httpSrv *httpServer
+	serveHTTP := s.httpPort != "" || s.httpListener != nil
+	if s.singlePort || serveHTTP {
+		httpSrv, err = s.newHTTPServer(s.singlePort)
 		if err != nil {
-			ll.Error("GRPC Server Error", l.Error(err))
+			lis.Close()
+			return nil, nil, err
 		}
-	}()
+		httpSrvs = append(httpSrvs, httpSrv)
+	}
+
+	ll.Info(s.name+" - GRPC Server started", l.Stringer("listen", lis.Addr()))
+	if s.singlePort {
+		go s.serve(httpSrv, lis, fail)
+	} else {
+		go func() {
+			// ErrServerStopped when shutdown began before serving
+			if err := s.grpcServer.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+				ll.Error("GRPC Server Error", l.Error(err))
+				fail(err)
+			}
+		}()
+	}
 
-	// Wait for OS signal or any error from services
-	<-ctx.Done()
+	if serveHTTP {
+		httpLis, err := s.listen(s.httpListener, s.httpPort)
+		if err != nil {
+			return httpSrvs, nil, err
+		}
+		ll.Info(s.name+" - HTTP Server started", l.Stringer("listen", httpLis.Addr()))
+		go s.serve(httpSrv, httpLis, fail)
+	}
+
+	if s.adminPort != "" || s.adminListener != nil {
+		adminLis, err := s.listen(s.adminListener, s.adminPort)
+		if err != nil {
+			return httpSrvs, nil, err
+		}
+		adminSrv := s.newAdminServer()
+		httpSrvs = append(httpSrvs, adminSrv)
+		ll.Info(s.name+" - Admin Server started", l.Stringer("listen", adminLis.Addr()))
+		go s.serve(adminSrv, adminLis, fail)
+	}
+
+	if s.registry != nil {
+		deregister, err = s.register()
+		if err != nil {
+			ll.Error("Error registering service", l.Error(err))
+			return httpSrvs, nil, err
+		}
+	}
+	return httpSrvs, deregister, nil
+}
+
+// shutdown stops serving within shutdownTimeout, then stops components
+func (s *server) shutdown(httpSrvs []*httpServer, deregister func()) error {
+	s.healthServer.Shutdown()
+	if deregister != nil {
+		deregister()
+	}
 	ll.Info("Waiting for all requests to finish")
 
-	// Wait for maximum 15s
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
 	go func() {
-		timer := time.NewTimer(15 * time.Second)
-		<-timer.C
-		ll.Fatal("Force shutdown due to timeout!")
+		defer close(stopped)
+		for _, httpSrv := range httpSrvs {
+			httpSrv.shutdown(ctx)
+		}
+		s.grpcServer.GracefulStop()
 	}()
-	s.grpcServer.GracefulStop()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		ll.Error("Force shutdown due to timeout", l.Duration("timeout", s.shutdownTimeout))
+		for _, httpSrv := range httpSrvs {
+			httpSrv.Close()
+		}
+		s.grpcServer.Stop()
+		err = ErrShutdownTimeout
+	}
+	s.lifecycle.stop()
+	return err
 }
 
-func (s server) RegisterServer(fn RegisterHandler) error {
+func (s *server) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+func (s *server) RegisterServer(fn RegisterHandler) error {
 	if err := fn(s.grpcServer); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (s *server) AddHook(h Hook) {
+	s.lifecycle.addHook(h)
+}
+
+func (s *server) AddRunnable(name string, r Runnable, timeout time.Duration) {
+	s.lifecycle.addRunnable(name, r, timeout)
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-xtek/vuvo-go/certs"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestServer(t *testing.T, args Args) (Server, *grpc.ClientConn) {
+	lis := bufconn.Listen(1 << 20)
+	args.Name = "test"
+	args.Listener = lis
+	args.DisableInterceptors = append(args.DisableInterceptors, InterceptorTracing)
+	s, err := NewServer(args)
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+	return s, conn
+}
+
+func start(s Server) <-chan error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start(context.Background()) }()
+	return errCh
+}
+
+func wait(t *testing.T, errCh <-chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return")
+		return nil
+	}
+}
+
+func TestServerStartStop(t *testing.T) {
+	s, conn := newTestServer(t, Args{})
+	defer conn.Close()
+	errCh := start(s)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	assert.NotNil(t, s.Addr())
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.NoError(t, wait(t, errCh))
+	assert.Equal(t, ErrServerStarted, s.Start(context.Background()))
+}
+
+func TestServerContextDone(t *testing.T) {
+	s, conn := newTestServer(t, Args{})
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start(ctx) }()
+	cancel()
+	assert.NoError(t, wait(t, errCh))
+}
+
+func TestServerRunnableError(t *testing.T) {
+	s, conn := newTestServer(t, Args{})
+	defer conn.Close()
+	s.AddRunnable("worker", RunnableFunc(func(ctx context.Context) error {
+		return errors.New("connection lost")
+	}), 0)
+
+	err := wait(t, start(s))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection lost")
+}
+
+func TestServerHookError(t *testing.T) {
+	s, conn := newTestServer(t, Args{})
+	defer conn.Close()
+	s.AddHook(Hook{
+		Name:    "db",
+		OnStart: func(ctx context.Context) error { return errors.New("refused") },
+	})
+
+	assert.EqualError(t, wait(t, start(s)), "refused")
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	s, conn := newTestServer(t, Args{ShutdownTimeout: 50 * time.Millisecond})
+	defer conn.Close()
+	errCh := start(s)
+
+	// An open stream blocks the graceful stop
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.Equal(t, ErrShutdownTimeout, wait(t, errCh))
+}
+
+func TestServerListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	s, err := NewServer(Args{
+		Host:                "127.0.0.1",
+		Port:                port,
+		DisableInterceptors: []string{InterceptorTracing},
+	})
+	require.NoError(t, err)
+	assert.Error(t, wait(t, start(s)))
+}
+
+// writeCert writes a self-signed certificate for localhost, it is used as
+// server and client certificate and as client CA
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestServerTLSFiles(t *testing.T) {
+	for _, singlePort := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "server")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		certFile, keyFile := writeCert(t, dir)
+
+		lis := bufconn.Listen(1 << 20)
+		s, err := NewServer(Args{
+			Listener:            lis,
+			SinglePort:          singlePort,
+			DisableInterceptors: []string{InterceptorTracing},
+			TLS:                 &certs.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile},
+		})
+		require.NoError(t, err)
+		errCh := start(s)
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+		pool := x509.NewCertPool()
+		pool.AddCert(mustParse(t, cert.Certificate[0]))
+		conn, err := grpc.Dial("localhost",
+			grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return lis.Dial() }),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				RootCAs:      pool,
+				Certificates: []tls.Certificate{cert},
+			})),
+		)
+		require.NoError(t, err)
+
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		require.NoError(t, err, "single port %v", singlePort)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+		conn.Close()
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, wait(t, errCh))
+	}
+}
+
+func mustParse(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// tagsInterceptor records whether the built-in tags interceptor ran before it
+func tagsInterceptor(name string, calls *[]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if grpc_ctxtags.Extract(ctx) != grpc_ctxtags.NoopTags {
+			name += "+tags"
+		}
+		*calls = append(*calls, name)
+		return handler(ctx, req)
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var calls []string
+	s, conn := newTestServer(t, Args{
+		UnaryInterceptorsBefore: []grpc.UnaryServerInterceptor{tagsInterceptor("before", &calls)},
+		UnaryInterceptorsAfter:  []grpc.UnaryServerInterceptor{tagsInterceptor("after", &calls)},
+	})
+	defer conn.Close()
+	errCh := start(s)
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before", "after+tags"}, calls)
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.NoError(t, wait(t, errCh))
+}
+
+func TestDisableInterceptors(t *testing.T) {
+	var calls []string
+	s, conn := newTestServer(t, Args{
+		UnaryInterceptorsAfter: []grpc.UnaryServerInterceptor{tagsInterceptor("after", &calls)},
+		DisableInterceptors:    []string{InterceptorTags},
+	})
+	defer conn.Close()
+	errCh := start(s)
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"after"}, calls)
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.NoError(t, wait(t, errCh))
+
+	_, err = NewServer(Args{Port: "8080", DisableInterceptors: []string{InterceptorTracing, "unknown"}})
+	assert.Error(t, err)
+}
+
+func TestNewServerInvalidArgs(t *testing.T) {
+	_, err := NewServer(Args{DisableInterceptors: []string{InterceptorTracing}})
+	assert.Error(t, err)
+
+	_, err = NewServer(Args{Port: "8080"})
+	assert.Error(t, err)
+
+	_, err = NewServer(Args{
+		Port:                "8080",
+		DisableInterceptors: []string{InterceptorTracing},
+		TLS:                 &certs.Config{CertFile: "tls.crt", KeyFile: "tls.key"},
+		TLSConfig:           &tls.Config{},
+	})
+	assert.Error(t, err)
+
+	// Interceptors can not be set with GRPCOption
+	_, err = NewServer(Args{
+		Port:                "8080",
+		DisableInterceptors: []string{InterceptorTracing},
+		GRPCOption:          []grpc.ServerOption{grpc.UnaryInterceptor(grpc_ctxtags.UnaryServerInterceptor())},
+	})
+	assert.Error(t, err)
+}
+
+// dialTCP returns a connection to lis without TLS. Single port tests use TCP
+// listeners, as h2c hijacks connections which requires read deadlines.
+func dialTCP(t *testing.T, lis net.Listener) *grpc.ClientConn {
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	return conn
+}
+
+func TestSinglePort(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, err := NewServer(Args{
+		Listener:            lis,
+		SinglePort:          true,
+		DisableInterceptors: []string{InterceptorTracing},
+	})
+	require.NoError(t, err)
+	errCh := start(s)
+
+	// gRPC with h2c
+	conn := dialTCP(t, lis)
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// HTTP/1.1 on the same listener
+	base := "http://" + lis.Addr().String()
+	httpResp, err := http.Post(base+GatewayPrefix+"/grpc.health.v1.Health/Check", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	assert.Equal(t, 1, httpResp.ProtoMajor)
+
+	// Admin endpoints are only served on AdminPort
+	for _, path := range []string{"/debug/pprof/cmdline", CatalogPath} {
+		httpResp, err = http.Get(base + path)
+		require.NoError(t, err)
+		httpResp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, httpResp.StatusCode, path)
+	}
+
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.NoError(t, wait(t, errCh))
+}
+
+func TestSinglePortDraining(t *testing.T) {
+	s, err := newGRPCServer(nil)
+	require.NoError(t, err)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	h := &grpcHandler{grpcServer: s}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: h2c.NewHandler(h, &http2.Server{})}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	conn := dialTCP(t, lis)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+
+	// Requests received while draining get a trailers-only Unavailable
+	h.startDraining()
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "Server is shutting down", status.Convert(err).Message())
+	h.wait()
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-xtek/vuvo-go/l"
+)
+
+// SignalContext returns a context cancelled on SIGINT or SIGTERM, it is
+// passed to Start by main packages:
+//
+//	ctx, cancel := server.SignalContext(context.Background())
+//	defer cancel()
+//	if err := s.Start(ctx); err != nil {
+//		ll.Fatal("Server stopped", l.Error(err))
+//	}
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	osSignal := make(chan os.Signal, 1)
+	signal.Notify(osSignal, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(osSignal)
+		select {
+		case sig := <-osSignal:
+			ll.Info("Received OS signal", l.Stringer("signal", sig))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,186 @@
+// Package tokenadmin provides a gRPC service for operators to inspect and
+// revoke tokens of auth generators
+package tokenadmin
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:.. -I.. tokenadmin/tokenadmin.proto
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/l"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultAdminRole is required when no role is configured
+const DefaultAdminRole = "admin"
+
+var ll = l.New()
+
+// Config ...
+type Config struct {
+	// Generators are addressed by their names in requests
+	Generators map[string]auth.Generator
+
+	// HasRole reports whether the caller of ctx has given role
+	HasRole   func(ctx context.Context, role string) bool
+	AdminRole string
+}
+
+// Server implements TokenAdminServer
+type Server struct {
+	cfg Config
+}
+
+// NewServer returns new token admin server
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.HasRole == nil {
+		return nil, errors.New("TokenAdmin: HasRole required")
+	}
+	if cfg.AdminRole == "" {
+		cfg.AdminRole = DefaultAdminRole
+	}
+	return &Server{cfg: cfg}, nil
+}
+
+// Register registers the gRPC service, it can be passed to server.RegisterServer
+func (s *Server) Register(g *grpc.Server) error {
+	RegisterTokenAdminServer(g, s)
+	return nil
+}
+
+func (s *Server) authorize(ctx context.Context, generatorName string) (auth.Inspector, error) {
+	if !s.cfg.HasRole(ctx, s.cfg.AdminRole) {
+		return nil, status.Errorf(codes.PermissionDenied, "Role %v required", s.cfg.AdminRole)
+	}
+	g, ok := s.cfg.Generators[generatorName]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Generator %v not found", generatorName)
+	}
+	inspector, ok := g.(auth.Inspector)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Generator %v does not implement auth.Inspector", generatorName)
+	}
+	return inspector, nil
+}
+
+func (s *Server) audit(ctx context.Context, action string, fields ...l.Field) {
+	adminID := ""
+	if claim, ok := auth.FromContext(ctx); ok {
+		adminID = claim.Token.UserID
+		// The actor is the admin, not the user acting as
+		if claim.Token.IsDelegated() {
+			adminID = claim.Token.ActorID
+			fields = append(fields, l.String("on_behalf_of", claim.Token.UserID))
+		}
+	}
+	ll.Info("TokenAdmin: "+action, append(fields, l.String("admin", adminID))...)
+}
+
+func toProto(info auth.TokenInfo) *TokenInfo {
+	t := &TokenInfo{
+		Id:        info.ID,
+		Generator: info.SubjectID,
+		UserId:    info.UserID,
+		Value:     info.Value,
+		Ttl:       int32(info.TTL),
+		Active:    true,
+	}
+	if !info.CreatedAt.IsZero() {
+		t.CreatedAt = info.CreatedAt.Unix()
+	}
+	if !info.LastSeenAt.IsZero() {
+		t.LastSeenAt = info.LastSeenAt.Unix()
+	}
+	return t
+}
+
+// Introspect implements TokenAdminServer
+func (s *Server) Introspect(ctx context.Context, req *IntrospectRequest) (*TokenInfo, error) {
+	g, err := s.authorize(ctx, req.Generator)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := g.Introspect(req.Token)
+	if err == auth.ErrInvalid {
+		return &TokenInfo{Generator: req.Generator}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProto(info), nil
+}
+
+// Revoke implements TokenAdminServer
+func (s *Server) Revoke(ctx context.Context, req *RevokeRequest) (*RevokeResponse, error) {
+	g, err := s.authorize(ctx, req.Generator)
+	if err != nil {
+		return nil, err
+	}
+
+	id := req.Id
+	if req.Token != "" {
+		info, err := g.Introspect(req.Token)
+		if err == auth.ErrInvalid {
+			return &RevokeResponse{}, nil
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		id = info.ID
+	}
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "token or id required")
+	}
+
+	err = g.RevokeByID(id)
+	if err == auth.ErrInvalid {
+		return &RevokeResponse{}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.audit(ctx, "Revoke", l.String("generator", req.Generator), l.String("id", id))
+	return &RevokeResponse{Count: 1}, nil
+}
+
+// RevokeAllForUser implements TokenAdminServer
+func (s *Server) RevokeAllForUser(ctx context.Context, req *RevokeAllForUserRequest) (*RevokeResponse, error) {
+	g, err := s.authorize(ctx, req.Generator)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id required")
+	}
+
+	n, err := g.RevokeAllForUser(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.audit(ctx, "RevokeAllForUser", l.String("generator", req.Generator), l.String("user", req.UserId), l.Int("count", n))
+	return &RevokeResponse{Count: int32(n)}, nil
+}
+
+// ListTokens implements TokenAdminServer
+func (s *Server) ListTokens(ctx context.Context, req *ListTokensRequest) (*ListTokensResponse, error) {
+	g, err := s.authorize(ctx, req.Generator)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := g.ListTokens(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &ListTokensResponse{Tokens: make([]*TokenInfo, len(infos))}
+	for i, info := range infos {
+		resp.Tokens[i] = toProto(info)
+	}
+	return resp, nil
+}
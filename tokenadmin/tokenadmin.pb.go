@@ -0,0 +1,631 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: tokenadmin/tokenadmin.proto
+
+package tokenadmin
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type TokenInfo struct {
+	// id identifies the token in admin calls, it can not be used to authenticate
+	Id        string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Generator string `protobuf:"bytes,2,opt,name=generator,proto3" json:"generator,omitempty"`
+	UserId    string `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Value     string `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	// Remaining TTL in seconds, -1 means no expiration
+	Ttl int32 `protobuf:"varint,5,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// Unix timestamps, only available with sliding expiration
+	CreatedAt  int64 `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastSeenAt int64 `protobuf:"varint,7,opt,name=last_seen_at,json=lastSeenAt,proto3" json:"last_seen_at,omitempty"`
+	// active is false when the introspected token is invalid
+	Active               bool     `protobuf:"varint,8,opt,name=active,proto3" json:"active,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenInfo) Reset()         { *m = TokenInfo{} }
+func (m *TokenInfo) String() string { return proto.CompactTextString(m) }
+func (*TokenInfo) ProtoMessage()    {}
+func (*TokenInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{0}
+}
+
+func (m *TokenInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenInfo.Unmarshal(m, b)
+}
+func (m *TokenInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenInfo.Marshal(b, m, deterministic)
+}
+func (m *TokenInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenInfo.Merge(m, src)
+}
+func (m *TokenInfo) XXX_Size() int {
+	return xxx_messageInfo_TokenInfo.Size(m)
+}
+func (m *TokenInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenInfo proto.InternalMessageInfo
+
+func (m *TokenInfo) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *TokenInfo) GetGenerator() string {
+	if m != nil {
+		return m.Generator
+	}
+	return ""
+}
+
+func (m *TokenInfo) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *TokenInfo) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *TokenInfo) GetTtl() int32 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *TokenInfo) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *TokenInfo) GetLastSeenAt() int64 {
+	if m != nil {
+		return m.LastSeenAt
+	}
+	return 0
+}
+
+func (m *TokenInfo) GetActive() bool {
+	if m != nil {
+		return m.Active
+	}
+	return false
+}
+
+type IntrospectRequest struct {
+	Generator            string   `protobuf:"bytes,1,opt,name=generator,proto3" json:"generator,omitempty"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IntrospectRequest) Reset()         { *m = IntrospectRequest{} }
+func (m *IntrospectRequest) String() string { return proto.CompactTextString(m) }
+func (*IntrospectRequest) ProtoMessage()    {}
+func (*IntrospectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{1}
+}
+
+func (m *IntrospectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IntrospectRequest.Unmarshal(m, b)
+}
+func (m *IntrospectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IntrospectRequest.Marshal(b, m, deterministic)
+}
+func (m *IntrospectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IntrospectRequest.Merge(m, src)
+}
+func (m *IntrospectRequest) XXX_Size() int {
+	return xxx_messageInfo_IntrospectRequest.Size(m)
+}
+func (m *IntrospectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IntrospectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IntrospectRequest proto.InternalMessageInfo
+
+func (m *IntrospectRequest) GetGenerator() string {
+	if m != nil {
+		return m.Generator
+	}
+	return ""
+}
+
+func (m *IntrospectRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type RevokeRequest struct {
+	Generator string `protobuf:"bytes,1,opt,name=generator,proto3" json:"generator,omitempty"`
+	// Either the raw token or its id
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Id                   string   `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeRequest) Reset()         { *m = RevokeRequest{} }
+func (m *RevokeRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeRequest) ProtoMessage()    {}
+func (*RevokeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{2}
+}
+
+func (m *RevokeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeRequest.Unmarshal(m, b)
+}
+func (m *RevokeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeRequest.Merge(m, src)
+}
+func (m *RevokeRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeRequest.Size(m)
+}
+func (m *RevokeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeRequest proto.InternalMessageInfo
+
+func (m *RevokeRequest) GetGenerator() string {
+	if m != nil {
+		return m.Generator
+	}
+	return ""
+}
+
+func (m *RevokeRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *RevokeRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type RevokeAllForUserRequest struct {
+	Generator            string   `protobuf:"bytes,1,opt,name=generator,proto3" json:"generator,omitempty"`
+	UserId               string   `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeAllForUserRequest) Reset()         { *m = RevokeAllForUserRequest{} }
+func (m *RevokeAllForUserRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeAllForUserRequest) ProtoMessage()    {}
+func (*RevokeAllForUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{3}
+}
+
+func (m *RevokeAllForUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeAllForUserRequest.Unmarshal(m, b)
+}
+func (m *RevokeAllForUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeAllForUserRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeAllForUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeAllForUserRequest.Merge(m, src)
+}
+func (m *RevokeAllForUserRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeAllForUserRequest.Size(m)
+}
+func (m *RevokeAllForUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeAllForUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeAllForUserRequest proto.InternalMessageInfo
+
+func (m *RevokeAllForUserRequest) GetGenerator() string {
+	if m != nil {
+		return m.Generator
+	}
+	return ""
+}
+
+func (m *RevokeAllForUserRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+type RevokeResponse struct {
+	Count                int32    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeResponse) Reset()         { *m = RevokeResponse{} }
+func (m *RevokeResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeResponse) ProtoMessage()    {}
+func (*RevokeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{4}
+}
+
+func (m *RevokeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeResponse.Unmarshal(m, b)
+}
+func (m *RevokeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeResponse.Marshal(b, m, deterministic)
+}
+func (m *RevokeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeResponse.Merge(m, src)
+}
+func (m *RevokeResponse) XXX_Size() int {
+	return xxx_messageInfo_RevokeResponse.Size(m)
+}
+func (m *RevokeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeResponse proto.InternalMessageInfo
+
+func (m *RevokeResponse) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type ListTokensRequest struct {
+	Generator string `protobuf:"bytes,1,opt,name=generator,proto3" json:"generator,omitempty"`
+	// Optional, lists tokens of all users when empty
+	UserId               string   `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListTokensRequest) Reset()         { *m = ListTokensRequest{} }
+func (m *ListTokensRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTokensRequest) ProtoMessage()    {}
+func (*ListTokensRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{5}
+}
+
+func (m *ListTokensRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTokensRequest.Unmarshal(m, b)
+}
+func (m *ListTokensRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTokensRequest.Marshal(b, m, deterministic)
+}
+func (m *ListTokensRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTokensRequest.Merge(m, src)
+}
+func (m *ListTokensRequest) XXX_Size() int {
+	return xxx_messageInfo_ListTokensRequest.Size(m)
+}
+func (m *ListTokensRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTokensRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTokensRequest proto.InternalMessageInfo
+
+func (m *ListTokensRequest) GetGenerator() string {
+	if m != nil {
+		return m.Generator
+	}
+	return ""
+}
+
+func (m *ListTokensRequest) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+type ListTokensResponse struct {
+	Tokens               []*TokenInfo `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *ListTokensResponse) Reset()         { *m = ListTokensResponse{} }
+func (m *ListTokensResponse) String() string { return proto.CompactTextString(m) }
+func (*ListTokensResponse) ProtoMessage()    {}
+func (*ListTokensResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2bc3f3d04ade13fa, []int{6}
+}
+
+func (m *ListTokensResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTokensResponse.Unmarshal(m, b)
+}
+func (m *ListTokensResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTokensResponse.Marshal(b, m, deterministic)
+}
+func (m *ListTokensResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTokensResponse.Merge(m, src)
+}
+func (m *ListTokensResponse) XXX_Size() int {
+	return xxx_messageInfo_ListTokensResponse.Size(m)
+}
+func (m *ListTokensResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTokensResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTokensResponse proto.InternalMessageInfo
+
+func (m *ListTokensResponse) GetTokens() []*TokenInfo {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*TokenInfo)(nil), "vuvo.tokenadmin.TokenInfo")
+	proto.RegisterType((*IntrospectRequest)(nil), "vuvo.tokenadmin.IntrospectRequest")
+	proto.RegisterType((*RevokeRequest)(nil), "vuvo.tokenadmin.RevokeRequest")
+	proto.RegisterType((*RevokeAllForUserRequest)(nil), "vuvo.tokenadmin.RevokeAllForUserRequest")
+	proto.RegisterType((*RevokeResponse)(nil), "vuvo.tokenadmin.RevokeResponse")
+	proto.RegisterType((*ListTokensRequest)(nil), "vuvo.tokenadmin.ListTokensRequest")
+	proto.RegisterType((*ListTokensResponse)(nil), "vuvo.tokenadmin.ListTokensResponse")
+}
+
+func init() { proto.RegisterFile("tokenadmin/tokenadmin.proto", fileDescriptor_2bc3f3d04ade13fa) }
+
+var fileDescriptor_2bc3f3d04ade13fa = []byte{
+	// 425 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0x4d, 0x6f, 0xd4, 0x30,
+	0x14, 0x94, 0x13, 0x92, 0x76, 0x1f, 0xa5, 0xb4, 0x56, 0x45, 0xad, 0xe5, 0x2b, 0x0a, 0x12, 0xca,
+	0x69, 0x91, 0x96, 0x5f, 0x10, 0x0e, 0x40, 0x50, 0x0f, 0xc8, 0xa5, 0x17, 0x24, 0xb4, 0x0a, 0xc9,
+	0x03, 0x45, 0x0d, 0xf6, 0x62, 0xbf, 0xe4, 0x8f, 0x72, 0xe4, 0xcf, 0xa0, 0x38, 0x29, 0xd9, 0x6e,
+	0x68, 0xa9, 0xb4, 0x37, 0xbf, 0xf1, 0x64, 0xec, 0x99, 0xb1, 0x02, 0x8f, 0x49, 0x5f, 0xa2, 0xca,
+	0xcb, 0x1f, 0x95, 0x7a, 0x35, 0x2e, 0x17, 0x6b, 0xa3, 0x49, 0xf3, 0x87, 0x6d, 0xd3, 0xea, 0xc5,
+	0x08, 0xc7, 0xbf, 0x18, 0xcc, 0x3e, 0x75, 0x63, 0xa6, 0xbe, 0x69, 0x7e, 0x08, 0x5e, 0x55, 0x0a,
+	0x16, 0xb1, 0x64, 0x26, 0xbd, 0xaa, 0xe4, 0x4f, 0x60, 0xf6, 0x1d, 0x15, 0x9a, 0x9c, 0xb4, 0x11,
+	0x9e, 0x83, 0x47, 0x80, 0x9f, 0xc2, 0x5e, 0x63, 0xd1, 0xac, 0xaa, 0x52, 0xf8, 0x6e, 0x2f, 0xec,
+	0xc6, 0xac, 0xe4, 0x27, 0x10, 0xb4, 0x79, 0xdd, 0xa0, 0xb8, 0xe7, 0xe0, 0x7e, 0xe0, 0x47, 0xe0,
+	0x13, 0xd5, 0x22, 0x88, 0x58, 0x12, 0xc8, 0x6e, 0xc9, 0x9f, 0x02, 0x14, 0x06, 0x73, 0xc2, 0x72,
+	0x95, 0x93, 0x08, 0x23, 0x96, 0xf8, 0x72, 0x36, 0x20, 0x29, 0xf1, 0x08, 0x0e, 0xea, 0xdc, 0xd2,
+	0xca, 0x22, 0xaa, 0x8e, 0xb0, 0xe7, 0x08, 0xd0, 0x61, 0xe7, 0x88, 0x2a, 0x25, 0xfe, 0x08, 0xc2,
+	0xbc, 0xa0, 0xaa, 0x45, 0xb1, 0x1f, 0xb1, 0x64, 0x5f, 0x0e, 0x53, 0xfc, 0x0e, 0x8e, 0x33, 0x45,
+	0x46, 0xdb, 0x35, 0x16, 0x24, 0xf1, 0x67, 0x83, 0x96, 0xae, 0x9b, 0x61, 0xdb, 0x66, 0x4e, 0x20,
+	0x70, 0xb1, 0x0c, 0x36, 0xfb, 0x21, 0x3e, 0x87, 0x07, 0x12, 0x5b, 0x7d, 0x89, 0x3b, 0x88, 0x0c,
+	0xa9, 0xfa, 0x57, 0xa9, 0xc6, 0x1f, 0xe1, 0xb4, 0x17, 0x4d, 0xeb, 0xfa, 0xad, 0x36, 0x17, 0x16,
+	0xcd, 0xdd, 0xe4, 0x37, 0x02, 0xf7, 0x36, 0x03, 0x8f, 0x5f, 0xc2, 0xe1, 0xd5, 0x35, 0xed, 0x5a,
+	0x2b, 0x8b, 0xdd, 0x4d, 0x0a, 0xdd, 0x28, 0x72, 0x22, 0x81, 0xec, 0x87, 0xf8, 0x03, 0x1c, 0x9f,
+	0x55, 0x96, 0x5c, 0xe1, 0x76, 0xc7, 0x33, 0xdf, 0x03, 0xdf, 0xd4, 0x1a, 0xce, 0x5d, 0x42, 0xe8,
+	0x4c, 0x5b, 0xc1, 0x22, 0x3f, 0xb9, 0xbf, 0x9c, 0x2f, 0xb6, 0x5e, 0xdc, 0xe2, 0xef, 0x6b, 0x93,
+	0x03, 0x73, 0xf9, 0xdb, 0x03, 0x70, 0x68, 0xda, 0x11, 0xf8, 0x19, 0xc0, 0x58, 0x1e, 0x8f, 0x27,
+	0x02, 0x93, 0x66, 0xe7, 0xb7, 0x1c, 0xc2, 0x33, 0x08, 0xfb, 0x68, 0xf8, 0xb3, 0x09, 0xeb, 0x5a,
+	0xb5, 0xf3, 0xe7, 0x37, 0xee, 0x0f, 0xde, 0xbe, 0xc0, 0xd1, 0x76, 0x6f, 0x3c, 0xb9, 0xe1, 0xa3,
+	0x49, 0xb5, 0xff, 0x97, 0xbf, 0x00, 0x18, 0x03, 0xfd, 0x87, 0xef, 0x49, 0x73, 0xf3, 0x17, 0xb7,
+	0x72, 0x7a, 0xd9, 0x37, 0x07, 0x9f, 0x61, 0x24, 0x7c, 0x0d, 0xdd, 0x7f, 0xe0, 0xf5, 0x9f, 0x01,
+	0x00, 0x41, 0x36, 0x8f, 0x79, 0x26, 0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// TokenAdminClient is the client API for TokenAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TokenAdminClient interface {
+	Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*TokenInfo, error)
+	Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error)
+	RevokeAllForUser(ctx context.Context, in *RevokeAllForUserRequest, opts ...grpc.CallOption) (*RevokeResponse, error)
+	ListTokens(ctx context.Context, in *ListTokensRequest, opts ...grpc.CallOption) (*ListTokensResponse, error)
+}
+
+type tokenAdminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTokenAdminClient(cc *grpc.ClientConn) TokenAdminClient {
+	return &tokenAdminClient{cc}
+}
+
+func (c *tokenAdminClient) Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*TokenInfo, error) {
+	out := new(TokenInfo)
+	err := c.cc.Invoke(ctx, "/vuvo.tokenadmin.TokenAdmin/Introspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenAdminClient) Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error) {
+	out := new(RevokeResponse)
+	err := c.cc.Invoke(ctx, "/vuvo.tokenadmin.TokenAdmin/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenAdminClient) RevokeAllForUser(ctx context.Context, in *RevokeAllForUserRequest, opts ...grpc.CallOption) (*RevokeResponse, error) {
+	out := new(RevokeResponse)
+	err := c.cc.Invoke(ctx, "/vuvo.tokenadmin.TokenAdmin/RevokeAllForUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenAdminClient) ListTokens(ctx context.Context, in *ListTokensRequest, opts ...grpc.CallOption) (*ListTokensResponse, error) {
+	out := new(ListTokensResponse)
+	err := c.cc.Invoke(ctx, "/vuvo.tokenadmin.TokenAdmin/ListTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TokenAdminServer is the server API for TokenAdmin service.
+type TokenAdminServer interface {
+	Introspect(context.Context, *IntrospectRequest) (*TokenInfo, error)
+	Revoke(context.Context, *RevokeRequest) (*RevokeResponse, error)
+	RevokeAllForUser(context.Context, *RevokeAllForUserRequest) (*RevokeResponse, error)
+	ListTokens(context.Context, *ListTokensRequest) (*ListTokensResponse, error)
+}
+
+// UnimplementedTokenAdminServer can be embedded to have forward compatible implementations.
+type UnimplementedTokenAdminServer struct {
+}
+
+func (*UnimplementedTokenAdminServer) Introspect(ctx context.Context, req *IntrospectRequest) (*TokenInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Introspect not implemented")
+}
+func (*UnimplementedTokenAdminServer) Revoke(ctx context.Context, req *RevokeRequest) (*RevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (*UnimplementedTokenAdminServer) RevokeAllForUser(ctx context.Context, req *RevokeAllForUserRequest) (*RevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAllForUser not implemented")
+}
+func (*UnimplementedTokenAdminServer) ListTokens(ctx context.Context, req *ListTokensRequest) (*ListTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTokens not implemented")
+}
+
+func RegisterTokenAdminServer(s *grpc.Server, srv TokenAdminServer) {
+	s.RegisterService(&_TokenAdmin_serviceDesc, srv)
+}
+
+func _TokenAdmin_Introspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IntrospectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenAdminServer).Introspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vuvo.tokenadmin.TokenAdmin/Introspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenAdminServer).Introspect(ctx, req.(*IntrospectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenAdmin_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenAdminServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vuvo.tokenadmin.TokenAdmin/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenAdminServer).Revoke(ctx, req.(*RevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenAdmin_RevokeAllForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAllForUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenAdminServer).RevokeAllForUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vuvo.tokenadmin.TokenAdmin/RevokeAllForUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenAdminServer).RevokeAllForUser(ctx, req.(*RevokeAllForUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenAdmin_ListTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenAdminServer).ListTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vuvo.tokenadmin.TokenAdmin/ListTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenAdminServer).ListTokens(ctx, req.(*ListTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TokenAdmin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "vuvo.tokenadmin.TokenAdmin",
+	HandlerType: (*TokenAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Introspect",
+			Handler:    _TokenAdmin_Introspect_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _TokenAdmin_Revoke_Handler,
+		},
+		{
+			MethodName: "RevokeAllForUser",
+			Handler:    _TokenAdmin_RevokeAllForUser_Handler,
+		},
+		{
+			MethodName: "ListTokens",
+			Handler:    _TokenAdmin_ListTokens_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tokenadmin/tokenadmin.proto",
+}
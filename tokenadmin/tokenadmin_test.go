@@ -0,0 +1,79 @@
+package tokenadmin
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-xtek/vuvo-go/auth"
+	"github.com/go-xtek/vuvo-go/redis"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type keyAdmin struct{}
+
+func TestNewServerInvalidConfig(t *testing.T) {
+	_, err := NewServer(Config{})
+	assert.Error(t, err)
+}
+
+func TestTokenAdmin(T *testing.T) {
+	redisAddress := "redis://localhost:6379"
+	if os.Getenv("USE_DOCKER_HOST") == "1" {
+		redisAddress = "redis://dockerhost:6379"
+	}
+	g := auth.NewGenerator("admin-test", redis.NewWithPool(redisAddress))
+	s, err := NewServer(Config{
+		Generators: map[string]auth.Generator{"admin-test": g},
+		HasRole: func(ctx context.Context, role string) bool {
+			return ctx.Value(keyAdmin{}) != nil
+		},
+	})
+	require.NoError(T, err)
+	ctx := context.WithValue(context.Background(), keyAdmin{}, true)
+
+	userID := uuid.NewV4().String()
+	t1, err := g.Generate(userID, auth.DefaultTTL)
+	require.NoError(T, err)
+	_, err = g.Generate(userID, auth.DefaultTTL)
+	require.NoError(T, err)
+
+	T.Run("Require admin role", func(t *testing.T) {
+		_, err := s.ListTokens(context.Background(), &ListTokensRequest{Generator: "admin-test"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	T.Run("Introspect", func(t *testing.T) {
+		info, err := s.Introspect(ctx, &IntrospectRequest{Generator: "admin-test", Token: t1.TokenStr})
+		require.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, userID, info.UserId)
+		assert.NotContains(t, info.Id, t1.TokenStr)
+
+		info, err = s.Introspect(ctx, &IntrospectRequest{Generator: "admin-test", Token: "invalid"})
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+
+	T.Run("List and revoke", func(t *testing.T) {
+		resp, err := s.ListTokens(ctx, &ListTokensRequest{Generator: "admin-test", UserId: userID})
+		require.NoError(t, err)
+		require.Len(t, resp.Tokens, 2)
+
+		revoked, err := s.Revoke(ctx, &RevokeRequest{Generator: "admin-test", Id: resp.Tokens[0].Id})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), revoked.Count)
+
+		revoked, err = s.RevokeAllForUser(ctx, &RevokeAllForUserRequest{Generator: "admin-test", UserId: userID})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), revoked.Count)
+
+		_, err = g.Validate(t1.TokenStr)
+		assert.Equal(t, auth.ErrInvalid, err)
+	})
+}
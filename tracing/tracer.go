@@ -2,6 +2,7 @@ package tracing
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
@@ -10,6 +11,12 @@ import (
 
 // Init returns a newly configured tracer
 func Init(serviceName, host string) (opentracing.Tracer, error) {
+	tracer, _, err := New(serviceName, host)
+	return tracer, err
+}
+
+// New returns a newly configured tracer and a closer flushing its spans
+func New(serviceName, host string) (opentracing.Tracer, io.Closer, error) {
 	cfg := config.Configuration{
 		Sampler: &config.SamplerConfig{
 			Type:  "const",
@@ -22,9 +29,9 @@ func Init(serviceName, host string) (opentracing.Tracer, error) {
 		},
 	}
 
-	tracer, _, err := cfg.New(serviceName)
+	tracer, closer, err := cfg.New(serviceName)
 	if err != nil {
-		return nil, fmt.Errorf("new tracer error: %v", err)
+		return nil, nil, fmt.Errorf("new tracer error: %v", err)
 	}
-	return tracer, nil
+	return tracer, closer, nil
 }